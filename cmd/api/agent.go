@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Run modes (MODE)
+const (
+	modeServer     = "server"
+	modeAgent      = "agent"
+	modeAggregator = "aggregator"
+)
+
+// parseMode validates a MODE value
+func parseMode(raw string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(raw)); mode {
+	case modeServer, modeAgent, modeAggregator:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, want server, agent or aggregator", raw)
+	}
+}
+
+type agentConfig struct {
+	url   string // AGGREGATOR_URL; pushing is disabled when empty
+	token string
+	name  string
+}
+
+// enabled reports whether snapshots should be pushed to an aggregator
+func (c agentConfig) enabled() bool {
+	return c.url != ""
+}
+
+// agentReport is the body an agent POSTs to its aggregator for every snapshot
+type agentReport struct {
+	Name       string        `json:"name"`
+	ID         uint64        `json:"id"`         // the snapshot's ID on the agent, which vitals don't serialize
+	IntervalMs int64         `json:"intervalMs"` // collection interval, so the aggregator can tell when the agent goes quiet
+	Vitals     *SystemVitals `json:"vitals"`
+}
+
+// agentPusher POSTs each collector snapshot to a central aggregator
+type agentPusher struct {
+	url      string
+	token    string
+	name     string
+	interval time.Duration
+	client   *http.Client
+}
+
+func newAgentPusher(cfg agentConfig, interval time.Duration) (*agentPusher, error) {
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	return &agentPusher{
+		url:      u.String(),
+		token:    cfg.token,
+		name:     cfg.name,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// run pushes every snapshot the collector publishes until ctx is cancelled.
+// Snapshots published while a push is still in flight are skipped.
+func (p *agentPusher) run(ctx context.Context, c *collector) {
+	updates := c.subscribe()
+	defer c.unsubscribe(updates)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case vitals := <-updates:
+			if err := p.push(ctx, vitals); err != nil {
+				slog.Warn("pushing to aggregator failed", "url", p.url, "error", err)
+			}
+		}
+	}
+}
+
+func (p *agentPusher) push(ctx context.Context, vitals *SystemVitals) error {
+	body, err := json.Marshal(&agentReport{
+		Name:       p.name,
+		ID:         vitals.ID,
+		IntervalMs: p.interval.Milliseconds(),
+		Vitals:     vitals,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("aggregator responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
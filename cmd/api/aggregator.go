@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const (
+	// maxIngestBytes bounds the body of a single agent report
+	maxIngestBytes = 4 << 20
+
+	// staleIntervals is how many report intervals can pass without a report
+	// before a host is considered offline
+	staleIntervals = 3
+)
+
+// agentName restricts the names agents can report under, since they are
+// used in URLs
+var agentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// reportingHost is the latest report from one agent
+type reportingHost struct {
+	name     string
+	interval time.Duration
+	lastSeen time.Time
+	vitals   *SystemVitals
+
+	subscribers map[chan *SystemVitals]struct{}
+}
+
+// online reports whether the host reported within staleIntervals intervals
+func (h *reportingHost) online(now time.Time) bool {
+	return now.Sub(h.lastSeen) <= staleIntervals*h.interval
+}
+
+// hostRegistry keeps the latest snapshot pushed by each agent and fans new
+// snapshots out to that host's streams
+type hostRegistry struct {
+	mu    sync.RWMutex
+	hosts map[string]*reportingHost
+}
+
+func newHostRegistry() *hostRegistry {
+	return &hostRegistry{hosts: make(map[string]*reportingHost)}
+}
+
+// record stores a report and publishes it to the host's subscribers
+func (reg *hostRegistry) record(report *agentReport) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	host, ok := reg.hosts[report.Name]
+	if !ok {
+		host = &reportingHost{
+			name:        report.Name,
+			subscribers: make(map[chan *SystemVitals]struct{}),
+		}
+		reg.hosts[report.Name] = host
+		slog.Info("agent reporting", "name", report.Name)
+	}
+
+	host.interval = time.Duration(report.IntervalMs) * time.Millisecond
+	host.lastSeen = time.Now()
+	host.vitals = report.Vitals
+
+	// Skip streams that haven't drained the last snapshot
+	for ch := range host.subscribers {
+		select {
+		case ch <- report.Vitals:
+		default:
+		}
+	}
+}
+
+// subscribe registers a channel for a host's snapshots and returns the host's
+// latest snapshot and interval, reporting false for an unknown host
+func (reg *hostRegistry) subscribe(name string) (chan *SystemVitals, *SystemVitals, time.Duration, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	host, ok := reg.hosts[name]
+	if !ok {
+		return nil, nil, 0, false
+	}
+
+	ch := make(chan *SystemVitals, 1)
+	host.subscribers[ch] = struct{}{}
+
+	return ch, host.vitals, host.interval, true
+}
+
+func (reg *hostRegistry) unsubscribe(name string, ch chan *SystemVitals) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if host, ok := reg.hosts[name]; ok {
+		delete(host.subscribers, ch)
+	}
+}
+
+// HostStatus summarizes one reporting agent
+type HostStatus struct {
+	Name       string    `json:"name"`
+	Hostname   string    `json:"hostname"` // empty when the agent doesn't report host info
+	Online     bool      `json:"online"`   // false once no report arrived within 3 intervals
+	LastSeen   time.Time `json:"lastSeen"`
+	IntervalMs int64     `json:"intervalMs"`
+}
+
+// list returns every host that has reported, ordered by name
+func (reg *hostRegistry) list() []HostStatus {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	now := time.Now()
+	hosts := make([]HostStatus, 0, len(reg.hosts))
+	for _, host := range reg.hosts {
+		status := HostStatus{
+			Name:       host.name,
+			Online:     host.online(now),
+			LastSeen:   host.lastSeen,
+			IntervalMs: host.interval.Milliseconds(),
+		}
+		if host.vitals.HostInfo != nil {
+			status.Hostname = host.vitals.HostInfo.Hostname
+		}
+		hosts = append(hosts, status)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	return hosts
+}
+
+// ingestAuthMiddleware requires agents to present the ingest token, which is
+// INGEST_TOKEN or else API_TOKEN
+func (app *application) ingestAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			app.unauthorizedErrorResponse(w, r, errors.New("authorization header is missing or malformed"))
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(app.config.aggregator.token)) != 1 {
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid ingest token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ingestHandler accepts a snapshot pushed by an agent
+func (app *application) ingestHandler(w http.ResponseWriter, r *http.Request) {
+	var report agentReport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBytes)).Decode(&report); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid report: %w", err))
+		return
+	}
+
+	switch {
+	case !agentName.MatchString(report.Name):
+		app.badRequestResponse(w, r, fmt.Errorf("invalid agent name %q", report.Name))
+		return
+	case report.IntervalMs < 1:
+		app.badRequestResponse(w, r, errors.New("intervalMs must be positive"))
+		return
+	case report.Vitals == nil:
+		app.badRequestResponse(w, r, errors.New("vitals are required"))
+		return
+	}
+
+	report.Vitals.ID = report.ID
+	app.hosts.record(&report)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type hostsResponse struct {
+	Hosts []HostStatus `json:"hosts"`
+}
+
+// hostsHandler lists the agents that have reported to this aggregator
+func (app *application) hostsHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeMetric(w, &hostsResponse{Hosts: app.hosts.list()})
+}
+
+// hostSSEHandler streams the snapshots pushed by one agent. Like /sse it
+// accepts `fields` and `unit`. An `offline` event is sent when the agent
+// misses 3 intervals, and an `online` event when it reports again.
+func (app *application) hostSSEHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	opts, err := app.parsePayloadOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	updates, latest, interval, ok := app.hosts.subscribe(name)
+	if !ok {
+		app.notFoundResponse(w, r, fmt.Errorf("host %q has not reported", name))
+		return
+	}
+	defer app.hosts.unsubscribe(name, updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	app.sseConnected.Add(1)
+	defer app.sseConnected.Add(-1)
+
+	sendVitalsData(w, flusher, latest, opts, nil)
+
+	keepAlive := time.NewTicker(app.config.sse.keepAlive)
+	defer keepAlive.Stop()
+
+	stale := time.NewTimer(staleIntervals * interval)
+	defer stale.Stop()
+	offline := false
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.shutdown:
+			fmt.Fprint(w, "event: close\ndata: server closing\n\n")
+			flusher.Flush()
+			return
+		case vitals := <-updates:
+			if offline {
+				fmt.Fprintf(w, "event: online\ndata: %s\n\n", name)
+				offline = false
+			}
+			sendVitalsData(w, flusher, vitals, opts, nil)
+			stale.Reset(staleIntervals * interval)
+		case <-stale.C:
+			fmt.Fprintf(w, "event: offline\ndata: %s\n\n", name)
+			flusher.Flush()
+			offline = true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/RakibulBh/homeserver-vitals/internal/env"
+)
+
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// criticalMargin is how far past its threshold a value must be, relative to the
+// threshold, before a warning is escalated to critical
+const criticalMargin = 0.1
+
+// Alert describes a metric that is over its configured threshold
+type Alert struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Severity  string  `json:"severity"`
+}
+
+// thresholds holds the configured alert limits. A zero value disables the check.
+type thresholds struct {
+	cpuPercent    float64
+	memoryPercent float64
+	swapPercent   float64
+	commitRatio   float64
+	diskPercent   float64
+	diskMounts    map[string]float64 // per-mount overrides of diskPercent
+	temperature   float64            // °C
+	load1         float64
+	zombies       float64
+	journalErrors float64 // per minute
+	pingLoss      float64 // percent
+}
+
+// loadThresholds reads alert limits from the environment, falling back to defaults
+func loadThresholds(defaults thresholds) thresholds {
+	t := thresholds{
+		cpuPercent:    env.GetFloat64("ALERT_CPU_PERCENT", defaults.cpuPercent),
+		memoryPercent: env.GetFloat64("ALERT_MEMORY_PERCENT", defaults.memoryPercent),
+		swapPercent:   env.GetFloat64("ALERT_SWAP_PERCENT", defaults.swapPercent),
+		commitRatio:   env.GetFloat64("ALERT_COMMIT_RATIO", defaults.commitRatio),
+		diskPercent:   env.GetFloat64("ALERT_DISK_PERCENT", defaults.diskPercent),
+		diskMounts:    defaults.diskMounts,
+		temperature:   env.GetFloat64("ALERT_TEMPERATURE", defaults.temperature),
+		load1:         env.GetFloat64("ALERT_LOAD1", defaults.load1),
+		zombies:       env.GetFloat64("ALERT_ZOMBIES", defaults.zombies),
+		journalErrors: env.GetFloat64("ALERT_JOURNAL_ERROR_RATE", defaults.journalErrors),
+		pingLoss:      env.GetFloat64("ALERT_PING_LOSS", defaults.pingLoss),
+	}
+
+	if raw := env.GetString("ALERT_DISK_MOUNTS", ""); raw != "" {
+		t.diskMounts = parseMountThresholds(raw)
+	}
+
+	return t
+}
+
+// parseMountThresholds parses a list like "/=90,/mnt/media=95". Malformed
+// entries are skipped.
+func parseMountThresholds(raw string) map[string]float64 {
+	mounts := make(map[string]float64)
+
+	for _, entry := range strings.Split(raw, ",") {
+		mount, limit, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(limit), 64)
+		if err != nil {
+			continue
+		}
+		mounts[strings.TrimSpace(mount)] = value
+	}
+
+	return mounts
+}
+
+// evaluate returns an alert for every metric in vitals over its threshold
+func (t thresholds) evaluate(vitals *SystemVitals) []Alert {
+	alerts := make([]Alert, 0)
+	for _, m := range t.measure(vitals) {
+		if m.Severity != "" {
+			alerts = append(alerts, m)
+		}
+	}
+
+	return alerts
+}
+
+// measure returns a reading for every metric with a configured threshold.
+// Readings under their threshold have an empty severity.
+func (t thresholds) measure(vitals *SystemVitals) []Alert {
+	readings := make([]Alert, 0)
+
+	check := func(metric string, value, threshold float64) {
+		if threshold <= 0 {
+			return
+		}
+
+		reading := Alert{
+			Metric:    metric,
+			Value:     value,
+			Threshold: threshold,
+		}
+
+		if value >= threshold*(1+criticalMargin) {
+			reading.Severity = severityCritical
+		} else if value >= threshold {
+			reading.Severity = severityWarning
+		}
+
+		readings = append(readings, reading)
+	}
+
+	check("cpu", vitals.CPUUsage, t.cpuPercent)
+
+	if vitals.Memory != nil {
+		check("memory", vitals.Memory.UsedPercent, t.memoryPercent)
+	}
+
+	// Hosts without swap report zero and never trigger
+	if vitals.Swap != nil && vitals.Swap.Total > 0 {
+		check("swap", vitals.Swap.UsedPercent, t.swapPercent)
+	}
+
+	// Only Linux reports a commit limit
+	if vitals.MemoryCommitLimit > 0 {
+		check("commit", vitals.CommitRatio, t.commitRatio)
+	}
+
+	for _, d := range vitals.Disks {
+		limit, ok := t.diskMounts[d.MountPoint]
+		if !ok {
+			limit = t.diskPercent
+		}
+		check("disk:"+d.MountPoint, d.UsedPercent, limit)
+	}
+
+	for _, temp := range vitals.Temperature {
+		check("temperature:"+temp.SensorKey, temp.Temperature, t.temperature)
+	}
+
+	if vitals.LoadAvg != nil {
+		check("load1", vitals.LoadAvg.Load1, t.load1)
+	}
+
+	check("zombies", float64(vitals.Zombies), t.zombies)
+	check("journalErrors", vitals.JournalErrorRate, t.journalErrors)
+
+	for _, ping := range vitals.PingTargets {
+		check("ping:"+ping.Target, ping.Loss, t.pingLoss)
+	}
+
+	// Pools have no threshold: any state but ONLINE is critical. The value is
+	// 1 while the pool is unhealthy.
+	for _, pool := range vitals.ZFSPools {
+		reading := Alert{Metric: "zfs:" + pool.Name, Threshold: 1}
+		if pool.State != zfsPoolOnline {
+			reading.Value = 1
+			reading.Severity = severityCritical
+		}
+		readings = append(readings, reading)
+	}
+
+	// Likewise an md array with a failed or missing member is critical. The
+	// value is the number of members it is short.
+	for _, array := range vitals.RaidArrays {
+		reading := Alert{Metric: "raid:" + array.Device, Threshold: 1}
+		if array.degraded() {
+			reading.Value = float64(max(array.TotalDisks-array.ActiveDisks, array.FailedDisks))
+			reading.Severity = severityCritical
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings
+}
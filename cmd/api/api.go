@@ -1,23 +1,100 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"sync/atomic"
 	"time"
 
-	"github.com/RakibulBh/homeserver-vitals/internal/env"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 )
 
 type application struct {
-	config config
+	config     config
+	startTime  time.Time
+	source     source
+	collector  *collector
+	sseClients *sseLimiter
+	telemetry  *telemetry    // nil unless OTEL_EXPORTER_OTLP_ENDPOINT is set
+	historyDB  *historyDB    // nil unless HISTORY_DB is set
+	hosts      *hostRegistry // nil unless MODE=aggregator
+	keys       *apiKeys      // nil unless API_KEYS_FILE is set
+
+	// shutdown is closed when the server starts shutting down, so long-lived
+	// streams end instead of holding up the shutdown
+	shutdown chan struct{}
+
+	// sseConnected counts the open /sse and /hosts/{name}/sse streams
+	sseConnected atomic.Int64
+
+	// wsConnected counts the open /ws connections
+	wsConnected atomic.Int64
 }
 
 type config struct {
-	addr string
-	env  string
+	addr       string
+	env        string
+	mode       string     // MODE: server, or agent to only push to an aggregator
+	origins    originList // FRONTEND_URL
+	tempUnit   string
+	auth       authConfig
+	tls        tlsConfig
+	collector  collectorConfig
+	sse        sseConfig
+	influx     influxConfig
+	snapshot   snapshotConfig
+	otel       otelConfig
+	diskUsage  diskUsageConfig
+	logTail    logTailConfig
+	historyDB  historyDBConfig
+	agent      agentConfig
+	aggregator aggregatorConfig
+}
+
+type aggregatorConfig struct {
+	token string // INGEST_TOKEN, or API_TOKEN when unset
+}
+
+type sseConfig struct {
+	keepAlive     time.Duration
+	maxPerIP      int
+	keyframeEvery int // frames per full snapshot on ?delta=true streams
+}
+
+type collectorConfig struct {
+	interval       time.Duration
+	timeout        time.Duration
+	updateInterval time.Duration
+	options        collectOptions
+	groupIntervals map[string]time.Duration // by metric group name
+	historySize    int
+	thresholds     thresholds
+	alerts         alertConfig
+}
+
+type alertConfig struct {
+	webhookURL    string
+	webhookFormat string
+	email         emailConfig
+	consecutive   int
+}
+
+type tlsConfig struct {
+	certFile string
+	keyFile  string
+}
+
+// enabled reports whether both a certificate and a key were configured
+func (c tlsConfig) enabled() bool {
+	return c.certFile != "" && c.keyFile != ""
+}
+
+type authConfig struct {
+	token    string
+	keysFile string // API_KEYS_FILE
 }
 
 func (app *application) serve() http.Handler {
@@ -26,12 +103,22 @@ func (app *application) serve() http.Handler {
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	if app.telemetry != nil {
+		r.Use(app.telemetry.middleware)
+	}
+	r.Use(requestLogger)
 	r.Use(middleware.Recoverer)
 
+	// Compress JSON responses for clients that accept gzip. The SSE stream
+	// (text/event-stream) is deliberately left out so events aren't held back
+	// in the compressor's buffer.
+	r.Use(middleware.Compress(5, "application/json"))
+
 	// CORS
+	allowedOrigins, allowOriginFunc := app.config.origins.corsOrigins()
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{env.GetString("FRONTEND_URL", "http://localhost:3000")},
+		AllowedOrigins:   allowedOrigins,
+		AllowOriginFunc:  allowOriginFunc,
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		AllowCredentials: true,
@@ -41,17 +128,73 @@ func (app *application) serve() http.Handler {
 	// Healthcheck
 	r.Get("/health", app.healthCheck)
 
-	// initiate SSE
-	r.Get("/sse", app.initiateSSE)
+	// Build information
+	r.Get("/version", app.versionHandler)
+
+	// Reports pushed by agents, which authenticate with their own token
+	if app.hosts != nil {
+		r.With(app.ingestAuthMiddleware).Post("/ingest", app.ingestHandler)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(app.tokenAuthMiddleware)
+
+		// initiate SSE
+		r.With(app.sseLimitMiddleware).Get("/sse", app.initiateSSE)
+
+		// WebSocket alternative to SSE, sharing the same per-IP limit
+		r.With(app.sseLimitMiddleware).Get("/ws", app.wsHandler)
+
+		// Get Vitals
+		r.Get("/vitals", app.printVitals)
+
+		// Per-metric endpoints
+		r.Get("/cpu", app.cpuHandler)
+		r.Get("/memory", app.memoryHandler)
+		r.Get("/disk", app.diskHandler)
+		r.Get("/network", app.networkHandler)
+		r.Get("/load", app.loadHandler)
+		r.Get("/temperature", app.temperatureHandler)
+
+		// Buffered history for chart backfill
+		r.Get("/history", app.historyHandler)
+		r.Get("/history.csv", app.historyCSVHandler)
+
+		// Pending package updates
+		r.Get("/updates", app.updatesHandler)
+
+		// Service statistics
+		r.Get("/stats", app.statsHandler)
+
+		// Processes
+		r.Get("/processes", app.processesHandler)
+		r.With(app.requireAdminMiddleware).Post("/processes/{pid}/kill", app.killProcessHandler)
+		r.With(app.requireAdminMiddleware).Post("/processes/{pid}/signal", app.signalProcessHandler)
+		r.With(app.requireAdminMiddleware).Get("/ports", app.portsHandler)
+		r.With(app.requireAdminMiddleware).Get("/diskusage", app.diskUsageHandler)
+		r.With(app.requireAdminMiddleware, app.sseLimitMiddleware).Get("/logtail", app.logTailHandler)
 
-	// Get Vitals
-	r.Get("/vitals", app.printVitals)
+		// Re-read static hardware details after hotplug
+		r.With(app.requireAdminMiddleware).Post("/refresh/hardware", app.refreshHardwareHandler)
+
+		// Hosts reporting to this aggregator
+		if app.hosts != nil {
+			r.Get("/hosts", app.hostsHandler)
+			r.With(app.sseLimitMiddleware).Get("/hosts/{name}/sse", app.hostSSEHandler)
+		}
+	})
 
 	return r
 }
 
-func (app *application) run(mux http.Handler) error {
-	srv := http.Server{
+// shutdownTimeout bounds how long in-flight requests get to finish once
+// shutdown starts
+const shutdownTimeout = 10 * time.Second
+
+// server builds the HTTP server for mux. Shutting it down also ends the
+// long-lived streams.
+func (app *application) server(mux http.Handler) *http.Server {
+	srv := &http.Server{
 		Addr:              app.config.addr,
 		Handler:           mux,
 		ReadTimeout:       80 * time.Second,
@@ -59,7 +202,38 @@ func (app *application) run(mux http.Handler) error {
 		ReadHeaderTimeout: 50 * time.Second,
 	}
 
-	log.Printf("Starting HTTP server, listening on %s", app.config.addr)
+	// Shutdown waits for handlers to return, and stream handlers only do so
+	// once told to. WebSockets are hijacked and aren't waited for.
+	srv.RegisterOnShutdown(func() { close(app.shutdown) })
+
+	return srv
+}
+
+// run serves until ctx is cancelled and then shuts the server down gracefully
+func (app *application) run(ctx context.Context, mux http.Handler) error {
+	srv := app.server(mux)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if app.config.tls.enabled() {
+			slog.Info("starting HTTPS server", "addr", app.config.addr)
+			serveErr <- srv.ListenAndServeTLS(app.config.tls.certFile, app.config.tls.keyFile)
+			return
+		}
+
+		slog.Info("starting HTTP server", "addr", app.config.addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	return srv.ListenAndServe()
+	return srv.Shutdown(shutdownCtx)
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownClosesSSEStreams(t *testing.T) {
+	cfg := config{
+		sse: sseConfig{keepAlive: 10 * time.Millisecond, maxPerIP: 3, keyframeEvery: 12},
+		collector: collectorConfig{
+			interval:    time.Second,
+			timeout:     time.Second,
+			historySize: 10,
+		},
+	}
+	app := &application{
+		config:     cfg,
+		startTime:  time.Now(),
+		source:     &fakeSource{},
+		collector:  newCollector(cfg.collector, &fakeSource{}, nil, time.Now()),
+		sseClients: newSSELimiter(cfg.sse.maxPerIP),
+		shutdown:   make(chan struct{}),
+	}
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = app.server(app.serve())
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// The first keepalive shows the handler is in its stream loop
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && !strings.HasPrefix(lines.Text(), ": keepalive") {
+	}
+
+	const deadline = 2 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	start := time.Now()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > deadline/2 {
+		t.Errorf("Shutdown took %s, want well inside %s", elapsed, deadline)
+	}
+
+	var event, data string
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "close":
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if event != "close" || data != "server closing" {
+		t.Errorf("last event = %q with data %q, want close with %q", event, data, "server closing")
+	}
+}
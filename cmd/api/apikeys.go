@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// API key scopes. Admin keys can also do everything read keys can.
+const (
+	scopeRead  = "read"
+	scopeAdmin = "admin"
+)
+
+// apiKeys holds the keys loaded from API_KEYS_FILE. The file can be reloaded
+// while running, so deleting a line revokes that key.
+type apiKeys struct {
+	path string
+
+	mu     sync.RWMutex
+	scopes map[string]string // key -> scope
+}
+
+// loadAPIKeys reads the keys file at path
+func loadAPIKeys(path string) (*apiKeys, error) {
+	keys := &apiKeys{path: path}
+	if err := keys.reload(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// reload replaces the keys with the current contents of the file. On error
+// the previous keys stay in effect.
+func (k *apiKeys) reload() error {
+	f, err := os.Open(k.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scopes := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The scope follows the last colon, so keys may contain colons
+		i := strings.LastIndex(line, ":")
+		if i < 1 {
+			return fmt.Errorf("line %d: want key:scope", n)
+		}
+		key, scope := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if scope != scopeRead && scope != scopeAdmin {
+			return fmt.Errorf("line %d: unknown scope %q, want read or admin", n, scope)
+		}
+		scopes[key] = scope
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.scopes = scopes
+	k.mu.Unlock()
+
+	return nil
+}
+
+// len returns the number of loaded keys
+func (k *apiKeys) len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return len(k.scopes)
+}
+
+// scope returns the scope of key, comparing against every key in constant
+// time, or "" when the key is unknown
+func (k *apiKeys) scope(key string) string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	found := ""
+	for candidate, scope := range k.scopes {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			found = scope
+		}
+	}
+
+	return found
+}
+
+// reloadKeysOnHangup reloads the keys file every time the process gets SIGHUP
+func reloadKeysOnHangup(keys *apiKeys) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		if err := keys.reload(); err != nil {
+			slog.Error("reloading API_KEYS_FILE failed, keeping the previous keys", "path", keys.path, "error", err)
+			continue
+		}
+		slog.Info("reloaded API keys", "path", keys.path, "keys", keys.len())
+	}
+}
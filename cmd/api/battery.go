@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BatteryInfo contains charge status for a battery or UPS
+type BatteryInfo struct {
+	Name          string  `json:"name"`
+	Percent       float64 `json:"percent"`
+	State         string  `json:"state"`
+	TimeRemaining uint64  `json:"timeRemaining"` // seconds to empty or full, 0 when unknown
+}
+
+const powerSupplyPath = "/sys/class/power_supply"
+
+// collectBatteries reads every battery exposed through the Linux power_supply
+// class. Desktops without batteries, and other platforms, get an empty list.
+func collectBatteries(vitals *SystemVitals) {
+	vitals.Battery = make([]BatteryInfo, 0)
+
+	entries, err := filepath.Glob(filepath.Join(powerSupplyPath, "*"))
+	if err != nil {
+		return
+	}
+
+	for _, dir := range entries {
+		// AC adapters and USB supplies live alongside batteries
+		if readSysString(filepath.Join(dir, "type")) != "Battery" {
+			continue
+		}
+
+		battery := BatteryInfo{
+			Name:  filepath.Base(dir),
+			State: strings.ToLower(readSysString(filepath.Join(dir, "status"))),
+		}
+		if battery.State == "" {
+			battery.State = "unknown"
+		}
+
+		if capacity, ok := readSysFloat(filepath.Join(dir, "capacity")); ok {
+			battery.Percent = capacity
+		}
+
+		battery.TimeRemaining = batteryTimeRemaining(dir, battery.State)
+
+		vitals.Battery = append(vitals.Battery, battery)
+	}
+}
+
+// batteryTimeRemaining estimates seconds until empty when discharging or until
+// full when charging. Drivers report either energy (µWh, µW) or charge
+// (µAh, µA) figures; both give hours when divided.
+func batteryTimeRemaining(dir, state string) uint64 {
+	now, full, rate := "energy_now", "energy_full", "power_now"
+	if _, err := os.Stat(filepath.Join(dir, now)); err != nil {
+		now, full, rate = "charge_now", "charge_full", "current_now"
+	}
+
+	level, ok := readSysFloat(filepath.Join(dir, now))
+	if !ok {
+		return 0
+	}
+	capacity, _ := readSysFloat(filepath.Join(dir, full))
+	drain, ok := readSysFloat(filepath.Join(dir, rate))
+	if !ok || drain <= 0 {
+		return 0
+	}
+
+	var hours float64
+	switch state {
+	case "discharging":
+		hours = level / drain
+	case "charging":
+		if capacity <= level {
+			return 0
+		}
+		hours = (capacity - level) / drain
+	default:
+		return 0
+	}
+
+	return uint64(hours * 3600)
+}
+
+// readSysString returns the trimmed contents of a sysfs attribute, or "" on error
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// readSysFloat parses a numeric sysfs attribute
+func readSysFloat(path string) (float64, bool) {
+	value, err := strconv.ParseFloat(readSysString(path), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
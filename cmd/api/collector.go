@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// collector gathers system vitals in the background on a fixed interval and
+// shares the latest snapshot with every connected client, so concurrent
+// streams cost a single collection pass.
+type collector struct {
+	source     source
+	startTime  time.Time
+	interval   time.Duration
+	timeout    time.Duration
+	options    collectOptions
+	history    *historyBuffer
+	thresholds thresholds
+	notifier   *alertNotifier
+	state      *collectState
+	stats      *collectorStats
+
+	// intervals holds each metric group's interval. Groups on a longer interval
+	// than the collector's are collected on their own ticker into scheduled,
+	// and their latest result is merged into every snapshot.
+	intervals map[string]time.Duration
+
+	scheduledMu sync.Mutex
+	scheduled   map[string]*SystemVitals
+
+	mu          sync.RWMutex
+	seq         uint64
+	latest      *SystemVitals
+	subscribers map[chan *SystemVitals]struct{}
+}
+
+func newCollector(cfg collectorConfig, src source, notifier *alertNotifier, startTime time.Time) *collector {
+	return &collector{
+		source:      src,
+		startTime:   startTime,
+		interval:    cfg.interval,
+		timeout:     cfg.timeout,
+		options:     cfg.options,
+		history:     newHistoryBuffer(cfg.historySize),
+		thresholds:  cfg.thresholds,
+		notifier:    notifier,
+		state:       newCollectState(),
+		stats:       newCollectorStats(),
+		intervals:   cfg.groupIntervals,
+		scheduled:   make(map[string]*SystemVitals),
+		subscribers: make(map[chan *SystemVitals]struct{}),
+	}
+}
+
+// separate reports whether a metric group runs on its own, longer interval
+func (c *collector) separate(group metricGroup) bool {
+	return c.intervals[group.name] > c.interval
+}
+
+// run collects immediately and then once per interval until ctx is cancelled.
+// Groups on their own interval are collected once up front, side by side so
+// the first snapshot waits for the slowest rather than all of them, and then
+// on their own tickers.
+func (c *collector) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, group := range metricGroups {
+		if c.separate(group) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.collectGroup(ctx, group)
+			}()
+			go c.runGroup(ctx, group)
+		}
+	}
+	wg.Wait()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.collect(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.collect(ctx)
+		}
+	}
+}
+
+// runGroup collects one metric group on its own interval until ctx is
+// cancelled
+func (c *collector) runGroup(ctx context.Context, group metricGroup) {
+	ticker := time.NewTicker(c.intervals[group.name])
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.collectGroup(ctx, group)
+		}
+	}
+}
+
+// collectGroup collects one metric group, bounded by the collection timeout,
+// and keeps the result for the following snapshots
+func (c *collector) collectGroup(ctx context.Context, group metricGroup) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	vitals := newSystemVitals()
+	group.collect(ctx, c.source, c.options, c.state, vitals)
+	c.stats.recordErrors(vitals.CollectionErrors, time.Now())
+
+	c.scheduledMu.Lock()
+	c.scheduled[group.name] = vitals
+	c.scheduledMu.Unlock()
+}
+
+// collect runs one collection pass bounded by the collection timeout. Groups
+// on their own interval contribute their latest result instead.
+func (c *collector) collect(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	vitals := newSystemVitals()
+	for _, group := range metricGroups {
+		if !c.separate(group) {
+			group.collect(ctx, c.source, c.options, c.state, vitals)
+		}
+	}
+	// Errors of separately collected groups were recorded when they ran
+	c.stats.recordErrors(vitals.CollectionErrors, time.Now())
+
+	c.scheduledMu.Lock()
+	for _, group := range metricGroups {
+		if latest := c.scheduled[group.name]; c.separate(group) && latest != nil {
+			mergeGroup(group, vitals, latest)
+		}
+	}
+	c.scheduledMu.Unlock()
+	finishSystemVitals(vitals)
+
+	vitals.AppUptime = uint64(time.Since(c.startTime).Seconds())
+	vitals.Alerts = c.thresholds.evaluate(vitals)
+
+	if c.notifier != nil {
+		c.notifier.observe(vitals, c.thresholds.measure(vitals))
+	}
+
+	c.mu.Lock()
+	c.seq++
+	vitals.ID = c.seq
+
+	c.latest = vitals
+	c.history.add(newHistorySample(vitals))
+
+	// Fan out to subscribers, skipping any that haven't drained the last snapshot
+	for ch := range c.subscribers {
+		select {
+		case ch <- vitals:
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	c.stats.observe(time.Since(start), c.interval, time.Now())
+}
+
+// snapshot returns the most recent vitals, or nil before the first collection
+func (c *collector) snapshot() *SystemVitals {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.latest
+}
+
+// subscribe registers a channel that receives every new snapshot
+func (c *collector) subscribe() chan *SystemVitals {
+	ch := make(chan *SystemVitals, 1)
+
+	c.mu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+
+	return ch
+}
+
+func (c *collector) unsubscribe(ch chan *SystemVitals) {
+	c.mu.Lock()
+	delete(c.subscribers, ch)
+	c.mu.Unlock()
+}
+
+// collectState carries readings between collection passes for metrics that
+// are computed from deltas
+type collectState struct {
+	processes   *processTracker
+	cpuTimes    *cpuTimesTracker
+	swap        *swapTracker
+	interfaces  *interfaceTracker
+	connections *connectionCache
+	services    *serviceCache
+	journal     *journalTracker
+	publicIP    *publicIPCache
+}
+
+func newCollectState() *collectState {
+	return &collectState{
+		processes:   newProcessTracker(),
+		cpuTimes:    newCPUTimesTracker(),
+		swap:        &swapTracker{},
+		interfaces:  newInterfaceTracker(),
+		connections: &connectionCache{},
+		services:    &serviceCache{},
+		journal:     &journalTracker{},
+		publicIP:    &publicIPCache{},
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// benchmarkProcesses is how many idle child processes BenchmarkCollect lists,
+// so the per-process work doesn't depend on what else runs on the machine
+const benchmarkProcesses = 100
+
+// benchmarkSource returns fixed readings for a small host, with the processes
+// listed as benchmarkProcesses children that are killed when b ends
+func benchmarkSource(b *testing.B) *fakeSource {
+	b.Helper()
+	if runtime.GOOS == "windows" {
+		b.Skip("needs sleep(1)")
+	}
+
+	src := &fakeSource{
+		cpuPercent: []float64{12, 30, 8, 50},
+		memory:     &mem.VirtualMemoryStat{Total: 16 << 30, Available: 10 << 30, Used: 6 << 30, UsedPercent: 37.5},
+		swap:       &mem.SwapMemoryStat{Total: 4 << 30, Used: 1 << 30, UsedPercent: 25},
+		partitions: []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			{Device: "/dev/sdb1", Mountpoint: "/mnt/media", Fstype: "ext4"},
+			{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		},
+		usage: map[string]*disk.UsageStat{
+			"/":          {Path: "/", Total: 500 << 30, Used: 200 << 30, UsedPercent: 40},
+			"/mnt/media": {Path: "/mnt/media", Total: 4 << 40, Used: 3 << 40, UsedPercent: 75},
+		},
+		diskIO: map[string]disk.IOCountersStat{
+			"sda": {Name: "sda", ReadBytes: 1 << 30, WriteBytes: 2 << 30},
+			"sdb": {Name: "sdb", ReadBytes: 5 << 30, WriteBytes: 1 << 30},
+		},
+		netIO: []net.IOCountersStat{
+			{Name: "lo", BytesRecv: 1 << 20, BytesSent: 1 << 20},
+			{Name: "eth0", BytesRecv: 9 << 30, BytesSent: 2 << 30},
+		},
+		interfaces: []net.InterfaceStat{
+			{Name: "lo", Flags: []string{"up", "loopback"}, Addrs: []net.InterfaceAddr{{Addr: "127.0.0.1/8"}}},
+			{Name: "eth0", Flags: []string{"up"}, Addrs: []net.InterfaceAddr{{Addr: "192.0.2.2/24"}, {Addr: "2001:db8::2/64"}}},
+		},
+		loadAvg: &load.AvgStat{Load1: 0.5, Load5: 0.4, Load15: 0.3},
+	}
+
+	for range benchmarkProcesses {
+		cmd := exec.Command("sleep", "3600")
+		if err := cmd.Start(); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		})
+
+		p, err := process.NewProcess(int32(cmd.Process.Pid))
+		if err != nil {
+			b.Fatal(err)
+		}
+		src.processes = append(src.processes, p)
+	}
+
+	return src
+}
+
+// BenchmarkCollect measures a full collection pass over benchmarkSource. The
+// metrics read outside the source, such as CPU times and host info, still come
+// from the machine.
+func BenchmarkCollect(b *testing.B) {
+	src := benchmarkSource(b)
+	opts := collectOptions{
+		processes: processOptions{sortBy: processSortCPU, limit: 5},
+	}
+	state := newCollectState()
+	ctx := context.Background()
+
+	// Prime the trackers, including the process warm-up sample
+	collectSystemVitals(ctx, src, opts, state)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		collectSystemVitals(ctx, src, opts, state)
+	}
+}
+
+// BenchmarkCollectProcesses isolates the per-process work of a pass
+func BenchmarkCollectProcesses(b *testing.B) {
+	src := benchmarkSource(b)
+	opts := processOptions{sortBy: processSortCPU, limit: 5}
+	tracker := newProcessTracker()
+	ctx := context.Background()
+
+	collectProcesses(ctx, src, newSystemVitals(), opts, tracker)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		collectProcesses(ctx, src, newSystemVitals(), opts, tracker)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of the optional config file. YAML is a superset of
+// JSON, so either format can be used. Keys left out keep the built-in defaults,
+// and environment variables override whatever the file sets.
+type fileConfig struct {
+	Addr        string        `yaml:"addr"`
+	Env         string        `yaml:"env"`
+	FrontendURL string        `yaml:"frontendUrl"`
+	Interval    time.Duration `yaml:"interval"`
+	TLS         struct {
+		CertFile string `yaml:"certFile"`
+		KeyFile  string `yaml:"keyFile"`
+	} `yaml:"tls"`
+	Thresholds struct {
+		CPUPercent    float64            `yaml:"cpuPercent"`
+		MemoryPercent float64            `yaml:"memoryPercent"`
+		SwapPercent   float64            `yaml:"swapPercent"`
+		CommitRatio   float64            `yaml:"commitRatio"`
+		DiskPercent   float64            `yaml:"diskPercent"`
+		DiskMounts    map[string]float64 `yaml:"diskMounts"`
+		Temperature   float64            `yaml:"temperature"`
+		Load1         float64            `yaml:"load1"`
+		Zombies       float64            `yaml:"zombies"`
+		JournalErrors float64            `yaml:"journalErrorRate"`
+		PingLoss      float64            `yaml:"pingLoss"`
+	} `yaml:"thresholds"`
+}
+
+func defaultFileConfig() fileConfig {
+	cfg := fileConfig{
+		Addr:        ":2000",
+		Env:         "development",
+		FrontendURL: "http://localhost:3000",
+		Interval:    5 * time.Second,
+	}
+	cfg.Thresholds.Zombies = 10
+
+	return cfg
+}
+
+// loadConfigFile reads the config file at path over the built-in defaults. An
+// empty path returns the defaults. Unknown keys are rejected to catch typos.
+func loadConfigFile(path string) (fileConfig, error) {
+	cfg := defaultFileConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// thresholds converts the file's alert limits
+func (f fileConfig) thresholds() thresholds {
+	mounts := f.Thresholds.DiskMounts
+	if mounts == nil {
+		mounts = make(map[string]float64)
+	}
+
+	return thresholds{
+		cpuPercent:    f.Thresholds.CPUPercent,
+		memoryPercent: f.Thresholds.MemoryPercent,
+		swapPercent:   f.Thresholds.SwapPercent,
+		commitRatio:   f.Thresholds.CommitRatio,
+		diskPercent:   f.Thresholds.DiskPercent,
+		diskMounts:    mounts,
+		temperature:   f.Thresholds.Temperature,
+		load1:         f.Thresholds.Load1,
+		zombies:       f.Thresholds.Zombies,
+		journalErrors: f.Thresholds.JournalErrors,
+		pingLoss:      f.Thresholds.PingLoss,
+	}
+}
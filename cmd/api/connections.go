@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+// connectionsEvery is how many collection passes a connection count is reused
+// for. Listing sockets walks every process's file descriptors on some
+// platforms, so it isn't refreshed on every pass.
+const connectionsEvery = 2
+
+// ConnectionStats counts TCP sockets, in total and by state (ESTABLISHED,
+// TIME_WAIT, CLOSE_WAIT, LISTEN, ...)
+type ConnectionStats struct {
+	Total  int            `json:"total"`
+	States map[string]int `json:"states"`
+}
+
+// connectionCache holds the last connection count between refreshes
+type connectionCache struct {
+	mu     sync.Mutex
+	passes int
+	stats  *ConnectionStats
+}
+
+// collectConnections reports TCP connection counts, refreshing them every
+// connectionsEvery passes
+func collectConnections(ctx context.Context, vitals *SystemVitals, cache *connectionCache) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.stats == nil || cache.passes%connectionsEvery == 0 {
+		conns, err := net.ConnectionsWithContext(ctx, "tcp")
+		if err != nil {
+			vitals.collectFailed("Connections", err)
+		} else {
+			stats := &ConnectionStats{
+				Total:  len(conns),
+				States: make(map[string]int),
+			}
+			for _, conn := range conns {
+				stats.States[conn.Status]++
+			}
+			cache.stats = stats
+		}
+	}
+	cache.passes++
+
+	if cache.stats != nil {
+		vitals.Connections = *cache.stats
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnectivityInfo reports whether the LAN gateway and the internet can be
+// reached, to tell a dead uplink apart from a dead LAN
+type ConnectivityInfo struct {
+	PublicIP          string `json:"publicIP"` // last address the resolver returned, empty until one succeeds
+	Gateway           string `json:"gateway"`  // address probed, empty when no default gateway was found
+	GatewayReachable  bool   `json:"gatewayReachable"`
+	InternetReachable bool   `json:"internetReachable"`
+}
+
+type connectivityOptions struct {
+	enabled     bool
+	publicIPURL string        // returns the caller's address as plain text; lookups are off when empty
+	publicIPTTL time.Duration // how long a looked up address is reused
+	gateway     string        // host:port, or empty to probe the default gateway
+	gatewayPort string        // port probed on the default gateway
+	internet    string        // host:port
+	timeout     time.Duration // per probe
+}
+
+// publicIPCache keeps the public address between lookups
+type publicIPCache struct {
+	mu      sync.Mutex
+	ip      string
+	fetched time.Time
+}
+
+// collectConnectivity runs the gateway, internet and public IP probes
+// concurrently, each bounded by its own timeout
+func collectConnectivity(ctx context.Context, vitals *SystemVitals, opts connectivityOptions, cache *publicIPCache) {
+	if !opts.enabled {
+		return
+	}
+
+	info := &ConnectivityInfo{Gateway: opts.gateway}
+	if info.Gateway == "" {
+		if gw, err := defaultGateway(); err == nil {
+			info.Gateway = net.JoinHostPort(gw.String(), opts.gatewayPort)
+		} else {
+			vitals.collectFailed("gateway", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	if info.Gateway != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info.GatewayReachable = reachable(ctx, info.Gateway, opts.timeout)
+		}()
+	}
+	if opts.internet != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info.InternetReachable = reachable(ctx, opts.internet, opts.timeout)
+		}()
+	}
+	var lookupErr error
+	if opts.publicIPURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info.PublicIP, lookupErr = cache.get(ctx, opts)
+		}()
+	}
+	wg.Wait()
+
+	if lookupErr != nil {
+		vitals.collectFailed("publicIP", lookupErr)
+	}
+	vitals.Connectivity = info
+}
+
+// reachable reports whether a TCP connection to addr is answered. A refused
+// connection counts, since the host had to be up to refuse it.
+func reachable(ctx context.Context, addr string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Is(err, syscall.ECONNREFUSED)
+	}
+	conn.Close()
+
+	return true
+}
+
+// get returns the cached public address, looking it up again once it is
+// older than the TTL. A failed lookup keeps returning the previous address.
+func (c *publicIPCache) get(ctx context.Context, opts connectivityOptions) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ip != "" && time.Since(c.fetched) < opts.publicIPTTL {
+		return c.ip, nil
+	}
+
+	ip, err := lookupPublicIP(ctx, opts.publicIPURL, opts.timeout)
+	if err != nil {
+		return c.ip, err
+	}
+	c.ip, c.fetched = ip, time.Now()
+
+	return ip, nil
+}
+
+func lookupPublicIP(ctx context.Context, url string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("public IP lookup responded with %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("public IP lookup returned %q, not an address", strings.TrimSpace(string(body)))
+	}
+
+	return ip.String(), nil
+}
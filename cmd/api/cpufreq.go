@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// cpufreqGlob matches the Linux cpufreq directory of every core
+const cpufreqGlob = "/sys/devices/system/cpu/cpu[0-9]*/cpufreq"
+
+// collectCPUFreq sets the current clock speed averaged across cores
+func collectCPUFreq(ctx context.Context, vitals *SystemVitals) {
+	if mhz, ok := cpufreqAverage("scaling_cur_freq"); ok {
+		vitals.CPUFreqMHz = mhz
+		return
+	}
+
+	mhz, err := cpuInfoMHz(ctx)
+	if err != nil {
+		vitals.collectFailed("CPU Frequency", err)
+		return
+	}
+	vitals.CPUFreqMHz = mhz
+}
+
+// cpuMaxFreq returns the highest clock speed the cores are rated for
+func cpuMaxFreq(ctx context.Context) float64 {
+	if mhz, ok := cpufreqAverage("cpuinfo_max_freq"); ok {
+		return mhz
+	}
+
+	mhz, _ := cpuInfoMHz(ctx)
+	return mhz
+}
+
+// cpufreqAverage averages a cpufreq attribute, reported in kHz, across cores
+// and converts it to MHz. It fails when cpufreq isn't available, as in most
+// VMs and containers.
+func cpufreqAverage(attribute string) (float64, bool) {
+	dirs, _ := filepath.Glob(cpufreqGlob)
+
+	var total float64
+	var count int
+	for _, dir := range dirs {
+		if khz, ok := readSysFloat(filepath.Join(dir, attribute)); ok {
+			total += khz
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return total / float64(count) / 1000, true
+}
+
+// cpuInfoMHz averages the clock speed reported by cpu.Info
+func cpuInfoMHz(ctx context.Context) (float64, error) {
+	infos, err := cpu.InfoWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, info := range infos {
+		total += info.Mhz
+	}
+	if len(infos) == 0 {
+		return 0, nil
+	}
+
+	return total / float64(len(infos)), nil
+}
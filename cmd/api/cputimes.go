@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// CPUTimesInfo splits CPU time over the last collection interval into
+// percentages. Guest time is already counted in User and Nice.
+type CPUTimesInfo struct {
+	CPU     string  `json:"cpu"`
+	User    float64 `json:"user"`
+	Nice    float64 `json:"nice"`
+	System  float64 `json:"system"`
+	Idle    float64 `json:"idle"`
+	IOWait  float64 `json:"iowait"`
+	IRQ     float64 `json:"irq"`
+	SoftIRQ float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+	Guest   float64 `json:"guest"`
+}
+
+// cpuTimesTracker keeps the previous cumulative times, per core and across all
+// of them, so each pass reports the split for the time since the last one
+type cpuTimesTracker struct {
+	mu        sync.Mutex
+	prev      map[string]cpu.TimesStat
+	prevTotal *cpu.TimesStat
+}
+
+func newCPUTimesTracker() *cpuTimesTracker {
+	return &cpuTimesTracker{
+		prev: make(map[string]cpu.TimesStat),
+	}
+}
+
+// collectCPUTimes reports the per-core and system-wide time split since the
+// previous pass. The first pass only records a baseline and reports nothing.
+func collectCPUTimes(ctx context.Context, vitals *SystemVitals, tracker *cpuTimesTracker) {
+	vitals.CPUTimes = make([]CPUTimesInfo, 0)
+
+	times, err := cpu.TimesWithContext(ctx, true)
+	if err != nil {
+		vitals.collectFailed("CPU Times", err)
+		return
+	}
+	total, err := cpu.TimesWithContext(ctx, false)
+	if err != nil || len(total) == 0 {
+		vitals.collectFailed("CPU Times", err)
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	for _, cur := range times {
+		prev, ok := tracker.prev[cur.CPU]
+		tracker.prev[cur.CPU] = cur
+		if !ok {
+			continue
+		}
+
+		if split, ok := cpuTimesSplit(prev, cur); ok {
+			vitals.CPUTimes = append(vitals.CPUTimes, split)
+		}
+	}
+
+	if prev := tracker.prevTotal; prev != nil {
+		if split, ok := cpuTimesSplit(*prev, total[0]); ok {
+			vitals.CPUBreakdown = split
+		}
+	}
+	tracker.prevTotal = &total[0]
+}
+
+// cpuUsageFromTimes derives the total and per-core busy percentages from the
+// time splits of a collected snapshot, without sampling. It reports false
+// before the collector has split any interval.
+func cpuUsageFromTimes(vitals *SystemVitals) (usage float64, perCore []float64, ok bool) {
+	if vitals.CPUBreakdown.CPU == "" {
+		return 0, nil, false
+	}
+
+	perCore = make([]float64, len(vitals.CPUTimes))
+	for i, t := range vitals.CPUTimes {
+		perCore[i] = 100 - t.Idle
+	}
+
+	return 100 - vitals.CPUBreakdown.Idle, perCore, true
+}
+
+// cpuTimesSplit returns each state's share of the time between two cumulative
+// readings, or false when no time passed
+func cpuTimesSplit(prev, cur cpu.TimesStat) (CPUTimesInfo, bool) {
+	total := cur.Total() - prev.Total()
+	if total <= 0 {
+		return CPUTimesInfo{}, false
+	}
+
+	percent := func(cur, prev float64) float64 {
+		return (cur - prev) / total * 100
+	}
+
+	return CPUTimesInfo{
+		CPU:     cur.CPU,
+		User:    percent(cur.User, prev.User),
+		Nice:    percent(cur.Nice, prev.Nice),
+		System:  percent(cur.System, prev.System),
+		Idle:    percent(cur.Idle, prev.Idle),
+		IOWait:  percent(cur.Iowait, prev.Iowait),
+		IRQ:     percent(cur.Irq, prev.Irq),
+		SoftIRQ: percent(cur.Softirq, prev.Softirq),
+		Steal:   percent(cur.Steal, prev.Steal),
+		Guest:   percent(cur.Guest+cur.GuestNice, prev.Guest+prev.GuestNice),
+	}, true
+}
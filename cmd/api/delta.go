@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// deltaEncoder shrinks a stream of JSON payloads by sending, between periodic
+// keyframes holding every field, only the top-level fields that changed since
+// the previous frame. Every payload in a stream has the same keys, so a delta
+// never needs to remove one.
+type deltaEncoder struct {
+	keyframeEvery int
+	frames        int
+	prev          map[string]json.RawMessage
+}
+
+func newDeltaEncoder(keyframeEvery int) *deltaEncoder {
+	return &deltaEncoder{keyframeEvery: keyframeEvery}
+}
+
+// encode returns the frame to send for payload, a JSON object, and whether it
+// is a keyframe. The first frame is always a keyframe.
+func (d *deltaEncoder) encode(payload []byte) ([]byte, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, false, err
+	}
+
+	keyframe := d.prev == nil || d.frames%d.keyframeEvery == 0
+	prev := d.prev
+	d.prev = fields
+	d.frames++
+
+	if keyframe {
+		return payload, true, nil
+	}
+
+	changed := make(map[string]json.RawMessage)
+	for key, value := range fields {
+		if !bytes.Equal(value, prev[key]) {
+			changed[key] = value
+		}
+	}
+
+	frame, err := json.Marshal(changed)
+	return frame, false, err
+}
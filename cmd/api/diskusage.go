@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type diskUsageConfig struct {
+	roots      []string // DISKUSAGE_ROOTS; the endpoint is disabled when empty
+	timeout    time.Duration
+	maxEntries int // filesystem entries visited per request
+}
+
+// DirUsage is the total size of the files under one directory
+type DirUsage struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Bytes uint64 `json:"bytes"`
+}
+
+type diskUsageResponse struct {
+	Path        string     `json:"path"`
+	Directories []DirUsage `json:"directories"` // largest first
+	TotalBytes  uint64     `json:"totalBytes"`
+	Entries     int        `json:"entries"`
+	// Truncated is set when the walk hit DISKUSAGE_TIMEOUT or
+	// DISKUSAGE_MAX_ENTRIES, so the sizes are lower bounds
+	Truncated   bool      `json:"truncated"`
+	DurationMs  int64     `json:"durationMs"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// errWalkLimit stops a walk once the timeout or entry cap is reached
+var errWalkLimit = errors.New("disk usage walk limit reached")
+
+// diskUsageHandler sizes each directory directly under ?path=, like
+// `du -s */`. Sizes are apparent file sizes, symlinks aren't followed and
+// unreadable directories are skipped. The path must be within one of
+// DISKUSAGE_ROOTS.
+func (app *application) diskUsageHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config.diskUsage
+	if len(cfg.roots) == 0 {
+		app.forbiddenResponse(w, r, errors.New("endpoint requires DISKUSAGE_ROOTS to be configured"))
+		return
+	}
+
+	path, err := allowedPath(r.URL.Query().Get("path"), cfg.roots, "DISKUSAGE_ROOTS")
+	if errors.Is(err, fs.ErrNotExist) {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		app.badRequestResponse(w, r, fmt.Errorf("path %q is not a directory", path))
+		return
+	}
+
+	children, err := os.ReadDir(path)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp := &diskUsageResponse{
+		Path:        path,
+		Directories: make([]DirUsage, 0),
+	}
+
+	for _, child := range children {
+		if !child.IsDir() {
+			continue
+		}
+
+		dir := DirUsage{Name: child.Name(), Path: filepath.Join(path, child.Name())}
+		err := filepath.WalkDir(dir.Path, func(_ string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil || resp.Entries >= cfg.maxEntries {
+				return errWalkLimit
+			}
+			resp.Entries++
+
+			if err != nil {
+				// Unreadable directories are skipped rather than failing the walk
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					dir.Bytes += uint64(info.Size())
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errWalkLimit) {
+			resp.Truncated = true
+		}
+
+		resp.Directories = append(resp.Directories, dir)
+		resp.TotalBytes += dir.Bytes
+		if resp.Truncated {
+			break
+		}
+	}
+
+	slices.SortFunc(resp.Directories, func(a, b DirUsage) int {
+		if a.Bytes != b.Bytes {
+			if a.Bytes > b.Bytes {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	resp.DurationMs = time.Since(start).Milliseconds()
+	resp.LastUpdated = time.Now()
+	app.writeMetric(w, resp)
+}
+
+// allowedPath resolves symlinks in the requested path and checks the result is
+// one of roots or inside one. setting names the variable holding the roots.
+func allowedPath(raw string, roots []string, setting string) (string, error) {
+	if raw == "" {
+		return "", errors.New("path is required")
+	}
+	if !filepath.IsAbs(raw) {
+		return "", fmt.Errorf("path %q must be absolute", raw)
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Clean(raw))
+	if err != nil {
+		return "", fmt.Errorf("path %q: %w", raw, err)
+	}
+
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("path %q is not within %s", raw, setting)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerInfo contains resource usage for a running Docker container
+type ContainerInfo struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Image       string  `json:"image"`
+	State       string  `json:"state"`
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+}
+
+// dockerOptions controls container metric collection
+type dockerOptions struct {
+	enabled bool
+	socket  string
+}
+
+// dockerContainer is the subset of the Docker API's container list entry we use
+type dockerContainer struct {
+	ID     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	Image  string   `json:"Image"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage uint64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// dockerStats is the subset of the Docker API's container stats we use
+type dockerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+// newDockerClient returns an HTTP client that talks to the Docker daemon over
+// its unix socket
+func newDockerClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// collectContainers gathers stats for every running container. Any failure to
+// reach the daemon leaves the list empty.
+func collectContainers(ctx context.Context, vitals *SystemVitals, opts dockerOptions) {
+	vitals.Containers = make([]ContainerInfo, 0)
+	if !opts.enabled {
+		return
+	}
+
+	client := newDockerClient(opts.socket)
+	defer client.CloseIdleConnections()
+
+	var containers []dockerContainer
+	if err := dockerGet(ctx, client, "/containers/json", &containers); err != nil {
+		vitals.collectFailed("Docker", err)
+		return
+	}
+
+	// Stats requests block while the daemon samples CPU, so fetch them concurrently
+	infos := make([]ContainerInfo, len(containers))
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, c dockerContainer) {
+			defer wg.Done()
+			infos[i] = containerInfo(ctx, client, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	vitals.Containers = infos
+}
+
+func containerInfo(ctx context.Context, client *http.Client, c dockerContainer) ContainerInfo {
+	info := ContainerInfo{
+		ID:    c.ID,
+		Image: c.Image,
+		State: c.State,
+	}
+	if len(c.ID) > 12 {
+		info.ID = c.ID[:12]
+	}
+	if len(c.Names) > 0 {
+		info.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	var stats dockerStats
+	if err := dockerGet(ctx, client, "/containers/"+c.ID+"/stats?stream=false", &stats); err != nil {
+		return info
+	}
+
+	info.CPUPercent = dockerCPUPercent(stats)
+	info.MemoryLimit = stats.MemoryStats.Limit
+
+	// Match `docker stats` by excluding reclaimable page cache
+	info.MemoryUsage = stats.MemoryStats.Usage
+	if cache, ok := stats.MemoryStats.Stats["inactive_file"]; ok && cache < info.MemoryUsage {
+		info.MemoryUsage -= cache
+	}
+
+	return info
+}
+
+// dockerCPUPercent applies the same formula as the docker CLI
+func dockerCPUPercent(stats dockerStats) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+func dockerGet(ctx context.Context, client *http.Client, path string, v any) error {
+	// The host is ignored when dialing the unix socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker API %s responded with %s", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+type emailConfig struct {
+	host     string
+	port     int
+	user     string
+	pass     string
+	from     string
+	to       []string
+	perHour  int // ALERT_EMAIL_PER_HOUR
+	implicit bool
+}
+
+// enabled reports whether a relay and at least one recipient are configured
+func (c emailConfig) enabled() bool {
+	return c.host != "" && len(c.to) > 0
+}
+
+// emailSink mails alert events through an SMTP relay. Port 465 uses implicit
+// TLS; other ports upgrade with STARTTLS when the server offers it.
+type emailSink struct {
+	cfg     emailConfig
+	limiter *rate.Limiter
+}
+
+func newEmailSink(cfg emailConfig) *emailSink {
+	// Allow a burst of a full hour's budget, refilled evenly over the hour
+	return &emailSink{
+		cfg:     cfg,
+		limiter: rate.NewLimiter(rate.Every(time.Hour/time.Duration(cfg.perHour)), cfg.perHour),
+	}
+}
+
+func (s *emailSink) send(event alertEvent) error {
+	// A flapping metric must not flood the inbox, so excess emails are dropped
+	if !s.limiter.Allow() {
+		slog.Warn("alert email rate limit reached, dropping email", "metric", event.Metric, "state", event.State)
+		return nil
+	}
+
+	addr := net.JoinHostPort(s.cfg.host, strconv.Itoa(s.cfg.port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.implicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.cfg.host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if !s.cfg.implicit {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.cfg.host}); err != nil {
+				return fmt.Errorf("starttls: %w", err)
+			}
+		}
+	}
+
+	if s.cfg.user != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.user, s.cfg.pass, s.cfg.host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.cfg.from); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(event)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message renders an alert event as a plain-text email
+func (s *emailSink) message(event alertEvent) []byte {
+	subject := fmt.Sprintf("[%s] %s on %s", strings.ToUpper(event.State), event.Metric, event.Hostname)
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", s.cfg.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.cfg.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+
+	if event.State == alertStateFiring {
+		fmt.Fprintf(&body, "%s on %s is %.2f, over its threshold of %.2f (%s).\r\n",
+			event.Metric, event.Hostname, event.Value, event.Threshold, event.Severity)
+	} else {
+		fmt.Fprintf(&body, "%s on %s is back to %.2f, under its threshold of %.2f.\r\n",
+			event.Metric, event.Hostname, event.Value, event.Threshold)
+	}
+	fmt.Fprintf(&body, "\r\nTime: %s\r\n", event.Timestamp.Format(time.RFC3339))
+
+	return body.Bytes()
+}
+
+// defaultEmailFrom uses the SMTP user when it is an address, or a local
+// address at this host
+func defaultEmailFrom(user string) string {
+	if strings.Contains(user, "@") {
+		return user
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return "vitals@" + hostname
+}
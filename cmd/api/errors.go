@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Error("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	writeJSONError(w, http.StatusInternalServerError, "the server encountered a problem")
+}
+
+func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Warn("bad request", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	writeJSONError(w, http.StatusBadRequest, err.Error())
+}
+
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Warn("not found", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	writeJSONError(w, http.StatusNotFound, "not found")
+}
+
+func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Warn("unauthorized", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="vitals"`)
+	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Warn("rate limit exceeded", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	w.Header().Set("Retry-After", "1")
+	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+}
+
+func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Warn("forbidden", "method", r.Method, "path", r.URL.Path, "error", err)
+
+	writeJSONError(w, http.StatusForbidden, "forbidden")
+}
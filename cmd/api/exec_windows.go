@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// killProcessGroupOnCancel relies on exec.CommandContext killing the process
+// itself; Windows has no process groups to signal
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// fileNrPath reports allocated, allocated-but-unused and maximum file handles
+const fileNrPath = "/proc/sys/fs/file-nr"
+
+// collectFileDescriptors reports host-wide open file handles and the kernel
+// limit. Linux only.
+func collectFileDescriptors(vitals *SystemVitals) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	data, err := os.ReadFile(fileNrPath)
+	if err != nil {
+		vitals.collectFailed("File Descriptors", err)
+		return
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 {
+		return
+	}
+
+	allocated, err1 := strconv.ParseUint(fields[0], 10, 64)
+	unused, err2 := strconv.ParseUint(fields[1], 10, 64)
+	max, err3 := strconv.ParseUint(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || unused > allocated {
+		return
+	}
+
+	vitals.OpenFileDescriptors = allocated - unused
+	vitals.MaxFileDescriptors = max
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// payloadOptions shapes the vitals sent to a client
+type payloadOptions struct {
+	fields   []string
+	tempUnit string
+	encoding string // SSE only: empty for JSON, encodingProtobuf or encodingMsgpack
+}
+
+// parsePayloadOptions reads the `fields` and `unit` query parameters, falling
+// back to the configured temperature unit
+func (app *application) parsePayloadOptions(r *http.Request) (payloadOptions, error) {
+	opts := payloadOptions{
+		fields:   parseFields(r.URL.Query().Get("fields")),
+		tempUnit: app.config.tempUnit,
+	}
+
+	if raw := r.URL.Query().Get("unit"); raw != "" {
+		unit, err := parseTempUnit(raw)
+		if err != nil {
+			return opts, err
+		}
+		opts.tempUnit = unit
+	}
+
+	return opts, nil
+}
+
+// apply converts and filters vitals for serialization
+func (opts payloadOptions) apply(vitals *SystemVitals) any {
+	return filterVitals(convertTemperatures(vitals, opts.tempUnit), opts.fields)
+}
+
+// vitalsFields maps a selectable field group to the payload keys it populates.
+// Groups are requested through the `fields` query parameter, e.g. ?fields=cpu,memory
+var vitalsFields = map[string]func(v *SystemVitals, out map[string]any){
+	"cpu": func(v *SystemVitals, out map[string]any) {
+		out["cpuUsage"] = v.CPUUsage
+		out["cpuPerCore"] = v.CPUPerCore
+		out["cpuTimes"] = v.CPUTimes
+		out["cpuBreakdown"] = v.CPUBreakdown
+		out["cpuFreqMHz"] = v.CPUFreqMHz
+	},
+	"memory": func(v *SystemVitals, out map[string]any) {
+		out["memory"] = v.Memory
+		out["memoryActualUsedPercent"] = v.MemoryActualUsedPercent
+		out["memoryCommitted"] = v.MemoryCommitted
+		out["memoryCommitLimit"] = v.MemoryCommitLimit
+		out["commitRatio"] = v.CommitRatio
+		out["swap"] = v.Swap
+		out["swapInRate"] = v.SwapInRate
+		out["swapOutRate"] = v.SwapOutRate
+		out["numaNodes"] = v.NumaNodes
+	},
+	"disk": func(v *SystemVitals, out map[string]any) {
+		out["disks"] = v.Disks
+		out["diskIO"] = v.DiskIO
+		out["smartHealth"] = v.SmartHealth
+		out["zfsPools"] = v.ZFSPools
+		out["raidArrays"] = v.RaidArrays
+	},
+	"network": func(v *SystemVitals, out map[string]any) {
+		out["network"] = v.Network
+		out["networkIfaces"] = v.NetworkIfaces
+		out["connections"] = v.Connections
+		out["connectivity"] = v.Connectivity
+		out["pingTargets"] = v.PingTargets
+	},
+	"host": func(v *SystemVitals, out map[string]any) {
+		out["hostInfo"] = v.HostInfo
+		out["uptime"] = v.Uptime
+		out["appUptime"] = v.AppUptime
+		out["users"] = v.Users
+		out["bootTime"] = v.BootTime
+		out["virtualizationSystem"] = v.VirtualizationSystem
+		out["virtualizationRole"] = v.VirtualizationRole
+	},
+	"hardware": func(v *SystemVitals, out map[string]any) {
+		out["hardware"] = v.Hardware
+	},
+	"load": func(v *SystemVitals, out map[string]any) {
+		out["loadAvg"] = v.LoadAvg
+		out["loadPerCore"] = v.LoadPerCore
+	},
+	"processes": func(v *SystemVitals, out map[string]any) {
+		out["processes"] = v.Processes
+		out["processStates"] = v.ProcessStates
+		out["zombies"] = v.Zombies
+		out["openFileDescriptors"] = v.OpenFileDescriptors
+		out["maxFileDescriptors"] = v.MaxFileDescriptors
+		out["topProcesses"] = v.TopProcesses
+	},
+	"temperature": func(v *SystemVitals, out map[string]any) {
+		out["temperature"] = v.Temperature
+		out["cpuPackageTemps"] = v.CPUPackageTemps
+		out["tempUnit"] = v.TempUnit
+	},
+	"runtime": func(v *SystemVitals, out map[string]any) {
+		out["goRoutines"] = v.GoRoutines
+		out["goMemAlloc"] = v.GoMemAlloc
+	},
+	"updates": func(v *SystemVitals, out map[string]any) {
+		out["systemUpdates"] = v.SystemUpdates
+	},
+	"gpu": func(v *SystemVitals, out map[string]any) {
+		out["gpu"] = v.GPU
+		out["tempUnit"] = v.TempUnit
+	},
+	"containers": func(v *SystemVitals, out map[string]any) {
+		out["containers"] = v.Containers
+	},
+	"services": func(v *SystemVitals, out map[string]any) {
+		out["services"] = v.Services
+		out["journalErrorRate"] = v.JournalErrorRate
+	},
+	"battery": func(v *SystemVitals, out map[string]any) {
+		out["battery"] = v.Battery
+	},
+	"alerts": func(v *SystemVitals, out map[string]any) {
+		out["alerts"] = v.Alerts
+	},
+}
+
+// parseFields splits a comma separated `fields` value into the known field
+// groups. Unknown names are ignored; nil means every field is wanted.
+func parseFields(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	fields := make([]string, 0)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := vitalsFields[name]; ok {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
+
+// filterVitals returns the payload to serialize for the requested field groups.
+// When no groups were requested the vitals are returned unchanged. The
+// timestamp and collection errors are always included.
+func filterVitals(vitals *SystemVitals, fields []string) any {
+	if fields == nil {
+		return vitals
+	}
+
+	out := map[string]any{
+		"lastUpdated":      vitals.LastUpdated,
+		"collectionErrors": vitals.CollectionErrors,
+	}
+	for _, name := range fields {
+		vitalsFields[name](vitals, out)
+	}
+
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// nameFilter selects names (interfaces, devices, mountpoints) by glob patterns.
+// A name is allowed when it matches an include pattern, or there are none, and
+// it matches no exclude pattern.
+type nameFilter struct {
+	include []string
+	exclude []string
+}
+
+func newNameFilter(include, exclude string) nameFilter {
+	return nameFilter{
+		include: parsePatterns(include),
+		exclude: parsePatterns(exclude),
+	}
+}
+
+func (f nameFilter) allows(name string) bool {
+	if len(f.include) > 0 && !matchesAny(name, f.include) {
+		return false
+	}
+
+	return !matchesAny(name, f.exclude)
+}
+
+// parsePatterns splits a comma separated list of glob patterns
+func parsePatterns(raw string) []string {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(raw, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// matchesAny reports whether name matches one of the filepath.Match patterns.
+// Malformed patterns never match.
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// defaultGateway is only supported on Linux; set GATEWAY_ADDR elsewhere
+func defaultGateway() (net.IP, error) {
+	return nil, errors.New("default gateway lookup is only supported on Linux, set GATEWAY_ADDR")
+}
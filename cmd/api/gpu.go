@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GPUInfo contains utilization and memory for a single GPU
+type GPUInfo struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name"`
+	Vendor      string  `json:"vendor"` // nvidia, amd or intel
+	Utilization float64 `json:"utilization"`
+	MemoryUsed  uint64  `json:"memoryUsed"`
+	MemoryTotal uint64  `json:"memoryTotal"`
+	Temperature float64 `json:"temperature"`
+}
+
+const nvidiaSMIQuery = "index,name,utilization.gpu,memory.used,memory.total,temperature.gpu"
+
+var (
+	nvidiaSMIOnce sync.Once
+	nvidiaSMIPath string
+)
+
+// lookupNvidiaSMI resolves the nvidia-smi binary once, logging when it is absent
+func lookupNvidiaSMI() string {
+	nvidiaSMIOnce.Do(func() {
+		path, err := exec.LookPath("nvidia-smi")
+		if err != nil {
+			slog.Info("nvidia-smi not found, NVIDIA GPU metrics disabled")
+			return
+		}
+		nvidiaSMIPath = path
+	})
+
+	return nvidiaSMIPath
+}
+
+// collectGPUs queries nvidia-smi for NVIDIA GPUs and adds AMD and Intel GPUs
+// found in sysfs
+func collectGPUs(ctx context.Context, vitals *SystemVitals) {
+	vitals.GPU = make([]GPUInfo, 0)
+
+	path := lookupNvidiaSMI()
+	if path != "" {
+		output, err := exec.CommandContext(ctx, path, "--query-gpu="+nvidiaSMIQuery, "--format=csv,noheader,nounits").Output()
+		if err != nil {
+			vitals.collectFailed("GPU", err)
+		} else {
+			vitals.GPU = parseNvidiaSMI(string(output))
+		}
+	}
+
+	vitals.GPU = append(vitals.GPU, collectDRMGPUs(len(vitals.GPU), path != "")...)
+}
+
+// parseNvidiaSMI parses nvidia-smi CSV output. Memory is reported in MiB and
+// converted to bytes; unsupported values ("[N/A]") are left at zero.
+func parseNvidiaSMI(output string) []GPUInfo {
+	gpus := make([]GPUInfo, 0)
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		cols := strings.Split(line, ",")
+		if len(cols) != 6 {
+			continue
+		}
+		for i := range cols {
+			cols[i] = strings.TrimSpace(cols[i])
+		}
+
+		index, _ := strconv.Atoi(cols[0])
+		utilization, _ := strconv.ParseFloat(cols[2], 64)
+		memUsed, _ := strconv.ParseFloat(cols[3], 64)
+		memTotal, _ := strconv.ParseFloat(cols[4], 64)
+		temperature, _ := strconv.ParseFloat(cols[5], 64)
+
+		gpus = append(gpus, GPUInfo{
+			Index:       index,
+			Name:        cols[1],
+			Vendor:      "nvidia",
+			Utilization: utilization,
+			MemoryUsed:  uint64(memUsed * 1024 * 1024),
+			MemoryTotal: uint64(memTotal * 1024 * 1024),
+			Temperature: temperature,
+		})
+	}
+
+	return gpus
+}
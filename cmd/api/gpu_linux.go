@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// drmCard matches DRM card directories, skipping connectors like card0-HDMI-A-1
+var drmCard = regexp.MustCompile(`^card(\d+)$`)
+
+// pciVendors maps PCI vendor IDs to the vendor reported in GPUInfo
+var pciVendors = map[string]string{
+	"0x1002": "amd",
+	"0x8086": "intel",
+	"0x10de": "nvidia",
+}
+
+// collectDRMGPUs reads GPUs exposed through DRM and hwmon sysfs nodes.
+// Utilization and VRAM are only exposed by some drivers (amdgpu) and are left
+// at zero otherwise. NVIDIA cards are skipped when nvidia-smi already reports
+// them. Indexes continue from firstIndex.
+func collectDRMGPUs(firstIndex int, skipNvidia bool) []GPUInfo {
+	gpus := make([]GPUInfo, 0)
+
+	cards, _ := filepath.Glob("/sys/class/drm/card*")
+	for _, card := range cards {
+		if !drmCard.MatchString(filepath.Base(card)) {
+			continue
+		}
+
+		device := filepath.Join(card, "device")
+		vendor, ok := pciVendors[readSysString(filepath.Join(device, "vendor"))]
+		if !ok || (skipNvidia && vendor == "nvidia") {
+			continue
+		}
+
+		gpu := GPUInfo{
+			Index:  firstIndex + len(gpus),
+			Name:   drmGPUName(device, vendor),
+			Vendor: vendor,
+		}
+		gpu.Utilization, _ = readSysFloat(filepath.Join(device, "gpu_busy_percent"))
+		if used, ok := readSysFloat(filepath.Join(device, "mem_info_vram_used")); ok {
+			gpu.MemoryUsed = uint64(used)
+		}
+		if total, ok := readSysFloat(filepath.Join(device, "mem_info_vram_total")); ok {
+			gpu.MemoryTotal = uint64(total)
+		}
+
+		// The first sensor is the die ("edge") temperature, in millidegrees
+		temps, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*", "temp*_input"))
+		if len(temps) > 0 {
+			if milli, ok := readSysFloat(temps[0]); ok {
+				gpu.Temperature = milli / 1000
+			}
+		}
+
+		gpus = append(gpus, gpu)
+	}
+
+	return gpus
+}
+
+// drmGPUName returns the marketing name where the driver exposes one, or the
+// vendor and PCI device ID
+func drmGPUName(device, vendor string) string {
+	if name := readSysString(filepath.Join(device, "product_name")); name != "" {
+		return name
+	}
+
+	id := readSysString(filepath.Join(device, "device"))
+	if n, err := strconv.ParseUint(strings.TrimPrefix(id, "0x"), 16, 16); err == nil {
+		id = "0x" + strconv.FormatUint(n, 16)
+	}
+
+	return strings.ToUpper(vendor[:1]) + vendor[1:] + " GPU " + id
+}
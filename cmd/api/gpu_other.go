@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// collectDRMGPUs is only supported on Linux
+func collectDRMGPUs(firstIndex int, skipNvidia bool) []GPUInfo {
+	return make([]GPUInfo, 0)
+}
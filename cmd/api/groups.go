@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// metricGroup is a set of metrics collected together. Each group can be
+// collected on its own interval, in which case its latest result is merged
+// into every snapshot until the next collection.
+type metricGroup struct {
+	name     string
+	env      string        // variable overriding the group's interval
+	interval time.Duration // default interval, used when longer than COLLECT_INTERVAL
+	collect  func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals)
+	merge    func(dst, src *SystemVitals) // copies the fields collect sets
+}
+
+// metricGroups are collected in this order
+var metricGroups = []metricGroup{
+	{
+		name: "cpu",
+		env:  "CPU_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectCPU(ctx, src, vitals, opts.cpuSample)
+			collectCPUTimes(ctx, vitals, state.cpuTimes)
+			collectCPUFreq(ctx, vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.CPUUsage = src.CPUUsage
+			dst.CPUPerCore = src.CPUPerCore
+			dst.CPUTimes = src.CPUTimes
+			dst.CPUBreakdown = src.CPUBreakdown
+			dst.CPUFreqMHz = src.CPUFreqMHz
+		},
+	},
+	{
+		name: "memory",
+		env:  "MEMORY_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectMemory(ctx, src, vitals)
+			collectSwapRates(vitals, state.swap)
+			collectNumaNodes(vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Memory = src.Memory
+			dst.MemoryActualUsedPercent = src.MemoryActualUsedPercent
+			dst.MemoryCommitted = src.MemoryCommitted
+			dst.MemoryCommitLimit = src.MemoryCommitLimit
+			dst.CommitRatio = src.CommitRatio
+			dst.Swap = src.Swap
+			dst.SwapInRate = src.SwapInRate
+			dst.SwapOutRate = src.SwapOutRate
+			dst.NumaNodes = src.NumaNodes
+		},
+	},
+	{
+		name: "disk",
+		env:  "DISK_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectDisks(ctx, src, vitals, opts.disks)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Disks = src.Disks
+			dst.DiskIO = src.DiskIO
+		},
+	},
+	{
+		name: "network",
+		env:  "NETWORK_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectNetwork(ctx, src, vitals, opts.interfaces, state.interfaces)
+			collectConnections(ctx, vitals, state.connections)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Network = src.Network
+			dst.NetworkIfaces = src.NetworkIfaces
+			dst.netRecvRate = src.netRecvRate
+			dst.netSendRate = src.netSendRate
+			dst.Connections = src.Connections
+		},
+	},
+	{
+		name: "host",
+		env:  "HOST_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectHost(ctx, vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.HostInfo = src.HostInfo
+			dst.BootTime = src.BootTime
+			dst.VirtualizationSystem = src.VirtualizationSystem
+			dst.VirtualizationRole = src.VirtualizationRole
+			dst.Uptime = src.Uptime
+			dst.Users = src.Users
+		},
+	},
+	{
+		name: "load",
+		env:  "LOAD_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectLoad(ctx, src, vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.LoadAvg = src.LoadAvg
+			dst.LoadPerCore = src.LoadPerCore
+		},
+	},
+	{
+		name: "processes",
+		env:  "PROCESS_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectProcesses(ctx, src, vitals, opts.processes, state.processes)
+			collectFileDescriptors(vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Processes = src.Processes
+			dst.ProcessStates = src.ProcessStates
+			dst.Zombies = src.Zombies
+			dst.TopProcesses = src.TopProcesses
+			dst.OpenFileDescriptors = src.OpenFileDescriptors
+			dst.MaxFileDescriptors = src.MaxFileDescriptors
+		},
+	},
+	{
+		name: "temperature",
+		env:  "TEMPERATURE_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectTemperatures(ctx, vitals, opts.temperature)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Temperature = src.Temperature
+			dst.CPUPackageTemps = src.CPUPackageTemps
+		},
+	},
+	{
+		name: "gpu",
+		env:  "GPU_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectGPUs(ctx, vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.GPU = src.GPU
+		},
+	},
+	{
+		name: "battery",
+		env:  "BATTERY_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectBatteries(vitals)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Battery = src.Battery
+		},
+	},
+	{
+		name: "smart",
+		env:  "SMART_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectSmartHealth(ctx, vitals, opts.smart)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.SmartHealth = src.SmartHealth
+		},
+	},
+	{
+		name: "zfs",
+		env:  "ZFS_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectZFSPools(ctx, vitals, opts.zfs)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.ZFSPools = src.ZFSPools
+		},
+	},
+	{
+		name: "raid",
+		env:  "RAID_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectRaidArrays(vitals, opts.raid)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.RaidArrays = src.RaidArrays
+		},
+	},
+	{
+		name: "containers",
+		env:  "CONTAINER_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectContainers(ctx, vitals, opts.docker)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Containers = src.Containers
+		},
+	},
+	{
+		name: "services",
+		env:  "SERVICE_INTERVAL",
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectServices(ctx, vitals, opts.services, state.services)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Services = src.Services
+		},
+	},
+	{
+		name:     "connectivity",
+		env:      "CONNECTIVITY_INTERVAL",
+		interval: 30 * time.Second,
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectConnectivity(ctx, vitals, opts.connectivity, state.publicIP)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.Connectivity = src.Connectivity
+		},
+	},
+	{
+		name:     "ping",
+		env:      "PING_INTERVAL",
+		interval: 30 * time.Second,
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectPings(ctx, vitals, opts.ping)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.PingTargets = src.PingTargets
+		},
+	},
+	{
+		name:     "journal",
+		env:      "JOURNAL_INTERVAL",
+		interval: time.Minute,
+		collect: func(ctx context.Context, src source, opts collectOptions, state *collectState, vitals *SystemVitals) {
+			collectJournalErrors(ctx, vitals, opts.journal, state.journal)
+		},
+		merge: func(dst, src *SystemVitals) {
+			dst.JournalErrorRate = src.JournalErrorRate
+		},
+	},
+}
+
+// mergeGroup copies a separately collected group, and the errors it hit, into
+// a snapshot
+func mergeGroup(group metricGroup, dst, src *SystemVitals) {
+	group.merge(dst, src)
+	maps.Copy(dst.CollectionErrors, src.CollectionErrors)
+}
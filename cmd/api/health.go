@@ -1,10 +1,159 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+const (
+	healthOK        = "ok"
+	healthDegraded  = "degraded"
+	healthUnhealthy = "unhealthy"
 )
 
+// staleAfterIntervals is how many collection intervals may pass without a new
+// snapshot before the collector is considered stuck
+const staleAfterIntervals = 3
+
+// healthProbeTimeout bounds the direct gopsutil probes
+const healthProbeTimeout = 2 * time.Second
+
+// criticalCollectors are the metrics, as named in CollectionErrors, whose
+// failure makes the service unhealthy rather than degraded
+var criticalCollectors = []string{"CPU Usage", "Memory"}
+
+type healthResponse struct {
+	Status           string                     `json:"status"`
+	AppUptime        uint64                     `json:"appUptime"` // seconds since the service started
+	ConnectedClients int64                      `json:"connectedClients"`
+	Checks           map[string]subsystemHealth `json:"checks"`
+}
+
+type subsystemHealth struct {
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	Error       string     `json:"error,omitempty"`
+	LastUpdated *time.Time `json:"lastUpdated,omitempty"`
+	AgeSeconds  float64    `json:"ageSeconds,omitempty"`
+}
+
+// healthCheck reports per-subsystem readiness and returns 503 when the
+// collector has gone stale, a critical collector failed or a critical probe
+// fails. ?simple=true keeps the plain "OK" liveness response.
 func (app *application) healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	if simple, _ := strconv.ParseBool(r.URL.Query().Get("simple")); simple {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthProbeTimeout)
+	defer cancel()
+
+	checks := map[string]subsystemHealth{
+		"collector": app.collectorHealth(),
+		"cpu": probe(true, func() error {
+			_, err := cpu.TimesWithContext(ctx, false)
+			return err
+		}),
+		"memory": probe(true, func() error {
+			_, err := mem.VirtualMemoryWithContext(ctx)
+			return err
+		}),
+		"load": probe(false, func() error {
+			_, err := load.AvgWithContext(ctx)
+			return err
+		}),
+		"host": probe(false, func() error {
+			_, err := host.UptimeWithContext(ctx)
+			return err
+		}),
+	}
+
+	status := healthOK
+	for _, check := range checks {
+		switch {
+		case check.Status == healthOK:
+		case check.Status == healthUnhealthy && check.Critical:
+			status = healthUnhealthy
+		case status != healthUnhealthy:
+			status = healthDegraded
+		}
+	}
+
+	code := http.StatusOK
+	if status == healthUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, &healthResponse{
+		Status:           status,
+		AppUptime:        uint64(time.Since(app.startTime).Seconds()),
+		ConnectedClients: app.sseConnected.Load(),
+		Checks:           checks,
+	})
+}
+
+// collectorHealth checks the shared collector has produced a recent snapshot
+// and that its last pass succeeded. A failed critical collector makes it
+// unhealthy, any other failure degraded.
+func (app *application) collectorHealth() subsystemHealth {
+	check := subsystemHealth{Status: healthOK, Critical: true}
+	maxAge := staleAfterIntervals * app.config.collector.interval
+
+	vitals := app.collector.snapshot()
+	if vitals == nil {
+		// Still within the first collection after startup
+		if time.Since(app.startTime) > maxAge {
+			check.Status = healthUnhealthy
+			check.Error = "no collection has completed"
+		}
+		return check
+	}
+
+	age := time.Since(vitals.LastUpdated)
+	check.LastUpdated = &vitals.LastUpdated
+	check.AgeSeconds = age.Seconds()
+
+	if age > maxAge {
+		check.Status = healthUnhealthy
+		check.Error = "last collection is older than " + maxAge.String()
+		return check
+	}
+
+	if len(vitals.CollectionErrors) == 0 {
+		return check
+	}
+
+	check.Status = healthDegraded
+	failed := make([]string, 0, len(vitals.CollectionErrors))
+	for metric, msg := range vitals.CollectionErrors {
+		failed = append(failed, metric+": "+msg)
+		if slices.Contains(criticalCollectors, metric) {
+			check.Status = healthUnhealthy
+		}
+	}
+	slices.Sort(failed)
+	check.Error = strings.Join(failed, "; ")
+
+	return check
+}
+
+func probe(critical bool, fn func() error) subsystemHealth {
+	check := subsystemHealth{Status: healthOK, Critical: critical}
+	if err := fn(); err != nil {
+		check.Status = healthUnhealthy
+		check.Error = err.Error()
+	}
+
+	return check
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectorHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		errors    map[string]string
+		status    string
+		wantError string
+	}{
+		{name: "fresh", age: time.Second, status: healthOK},
+		{name: "stale", age: time.Minute, status: healthUnhealthy, wantError: "last collection is older than 15s"},
+		{
+			name:      "failed collector",
+			age:       time.Second,
+			errors:    map[string]string{"SMART": "smartctl not found", "Docker": "no socket"},
+			status:    healthDegraded,
+			wantError: "Docker: no socket; SMART: smartctl not found",
+		},
+		{
+			name:      "failed critical collector",
+			age:       time.Second,
+			errors:    map[string]string{"Memory": "permission denied", "SMART": "smartctl not found"},
+			status:    healthUnhealthy,
+			wantError: "Memory: permission denied; SMART: smartctl not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := collectorConfig{interval: 5 * time.Second, timeout: time.Second, historySize: 1}
+			app := &application{
+				config:    config{collector: cfg},
+				startTime: time.Now().Add(-time.Hour),
+				collector: newCollector(cfg, &fakeSource{}, nil, time.Now()),
+			}
+			app.collector.latest = &SystemVitals{
+				LastUpdated:      time.Now().Add(-tt.age),
+				CollectionErrors: tt.errors,
+			}
+
+			check := app.collectorHealth()
+			if check.Status != tt.status {
+				t.Errorf("status = %q, want %q", check.Status, tt.status)
+			}
+			if check.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", check.Error, tt.wantError)
+			}
+		})
+	}
+}
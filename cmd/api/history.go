@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HistorySample is a compact snapshot of the headline metrics kept for charting
+type HistorySample struct {
+	ID                uint64    `json:"id"`
+	Timestamp         time.Time `json:"timestamp"`
+	CPUUsage          float64   `json:"cpuUsage"`
+	MemoryUsedPercent float64   `json:"memoryUsedPercent"`
+	Load1             float64   `json:"load1"`
+	NetRecvRate       float64   `json:"netRecvRate"` // bytes per second
+	NetSendRate       float64   `json:"netSendRate"` // bytes per second
+}
+
+// newHistorySample builds a sample from the current vitals
+func newHistorySample(current *SystemVitals) HistorySample {
+	sample := HistorySample{
+		ID:          current.ID,
+		Timestamp:   current.LastUpdated,
+		CPUUsage:    current.CPUUsage,
+		NetRecvRate: current.netRecvRate,
+		NetSendRate: current.netSendRate,
+	}
+
+	if current.Memory != nil {
+		sample.MemoryUsedPercent = current.Memory.UsedPercent
+	}
+
+	if current.LoadAvg != nil {
+		sample.Load1 = current.LoadAvg.Load1
+	}
+
+	return sample
+}
+
+// counterRate returns the per-second rate between two counter readings,
+// treating counter resets as zero
+func counterRate(previous, current uint64, elapsed float64) float64 {
+	if elapsed <= 0 || current < previous {
+		return 0
+	}
+
+	return float64(current-previous) / elapsed
+}
+
+// historyBuffer is a fixed size ring buffer of samples; once full the oldest
+// sample is overwritten
+type historyBuffer struct {
+	mu      sync.RWMutex
+	samples []HistorySample
+	next    int
+	full    bool
+}
+
+func newHistoryBuffer(size int) *historyBuffer {
+	if size < 1 {
+		size = 1
+	}
+
+	return &historyBuffer{
+		samples: make([]HistorySample, size),
+	}
+}
+
+func (h *historyBuffer) add(sample HistorySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the buffered samples ordered oldest first
+func (h *historyBuffer) list() []HistorySample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if !h.full {
+		out := make([]HistorySample, h.next)
+		copy(out, h.samples[:h.next])
+		return out
+	}
+
+	out := make([]HistorySample, 0, len(h.samples))
+	out = append(out, h.samples[h.next:]...)
+	out = append(out, h.samples[:h.next]...)
+
+	return out
+}
+
+// since returns the buffered samples with an ID greater than id, oldest first
+func (h *historyBuffer) since(id uint64) []HistorySample {
+	samples := h.list()
+	for i, sample := range samples {
+		if sample.ID > id {
+			return samples[i:]
+		}
+	}
+
+	return nil
+}
+
+// maxHistoryPoints caps ?points so a request can't ask for more buckets than
+// any buffer would reasonably hold
+const maxHistoryPoints = 10000
+
+func (app *application) historyHandler(w http.ResponseWriter, r *http.Request) {
+	samples, ok := app.queryHistory(w, r)
+	if !ok {
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, samples); err != nil {
+		slog.Error("writing history response", "error", err)
+	}
+}
+
+// queryHistory loads the samples within the ?from= and ?to= range, from the
+// history database when HISTORY_DB is set or the in-memory buffer otherwise,
+// and downsamples them. It writes the error response and reports false when
+// the request fails.
+func (app *application) queryHistory(w http.ResponseWriter, r *http.Request) ([]HistorySample, bool) {
+	from, to, err := historyRange(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	var samples []HistorySample
+	if app.historyDB != nil {
+		samples, err = app.historyDB.query(r.Context(), from, to)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+	} else {
+		samples = filterHistory(app.collector.history.list(), from, to)
+	}
+
+	samples, err = historyQuery(r, samples)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	return samples, true
+}
+
+// historyRange parses the optional ?from= and ?to= bounds. Each is an RFC 3339
+// timestamp or a duration before now, such as 6h or 7d. Unset bounds are zero.
+func historyRange(r *http.Request) (from, to time.Time, err error) {
+	parse := func(name string) (time.Time, error) {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		if ago, err := parseDayDuration(raw); err == nil {
+			return time.Now().Add(-ago), nil
+		}
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a duration such as 6h or 7d", name)
+	}
+
+	if from, err = parse("from"); err != nil {
+		return
+	}
+	if to, err = parse("to"); err != nil {
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		err = errors.New("to must not be before from")
+	}
+
+	return
+}
+
+// filterHistory returns the samples taken within [from, to]; zero bounds are open
+func filterHistory(samples []HistorySample, from, to time.Time) []HistorySample {
+	if from.IsZero() && to.IsZero() {
+		return samples
+	}
+
+	out := make([]HistorySample, 0, len(samples))
+	for _, sample := range samples {
+		if (from.IsZero() || !sample.Timestamp.Before(from)) && (to.IsZero() || !sample.Timestamp.After(to)) {
+			out = append(out, sample)
+		}
+	}
+
+	return out
+}
+
+// historyQuery applies the optional ?resolution= (bucket width, e.g. 1m) or
+// ?points= (bucket count) downsampling to the buffered samples
+func historyQuery(r *http.Request, samples []HistorySample) ([]HistorySample, error) {
+	query := r.URL.Query()
+	resolution, points := query.Get("resolution"), query.Get("points")
+
+	switch {
+	case resolution != "" && points != "":
+		return nil, errors.New("resolution and points cannot be combined")
+
+	case resolution != "":
+		width, err := time.ParseDuration(resolution)
+		if err != nil || width < time.Second {
+			return nil, errors.New("resolution must be a duration of at least 1s, e.g. 1m")
+		}
+		if len(samples) == 0 {
+			return samples, nil
+		}
+		return downsampleHistory(samples, samples[0].Timestamp.Truncate(width), width), nil
+
+	case points != "":
+		n, err := strconv.Atoi(points)
+		if err != nil || n < 1 || n > maxHistoryPoints {
+			return nil, fmt.Errorf("points must be between 1 and %d", maxHistoryPoints)
+		}
+		if len(samples) <= n {
+			return samples, nil
+		}
+		origin := samples[0].Timestamp
+		span := samples[len(samples)-1].Timestamp.Sub(origin)
+		// Round up so the newest sample falls in the last bucket
+		return downsampleHistory(samples, origin, span/time.Duration(n)+1), nil
+	}
+
+	return samples, nil
+}
+
+// downsampleHistory groups samples into consecutive buckets of the given width
+// starting at origin, and averages every metric within each bucket. Rates are
+// averaged too, so they stay in bytes per second. Each bucket takes the ID of
+// its newest sample and the timestamp of its start. Empty buckets are omitted.
+func downsampleHistory(samples []HistorySample, origin time.Time, width time.Duration) []HistorySample {
+	out := make([]HistorySample, 0)
+
+	var bucket HistorySample
+	var index int64 = -1
+	var count float64
+
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		bucket.CPUUsage /= count
+		bucket.MemoryUsedPercent /= count
+		bucket.Load1 /= count
+		bucket.NetRecvRate /= count
+		bucket.NetSendRate /= count
+		out = append(out, bucket)
+	}
+
+	for _, sample := range samples {
+		i := int64(sample.Timestamp.Sub(origin) / width)
+		if i != index {
+			flush()
+			index = i
+			count = 0
+			bucket = HistorySample{Timestamp: origin.Add(time.Duration(i) * width)}
+		}
+
+		bucket.ID = sample.ID
+		bucket.CPUUsage += sample.CPUUsage
+		bucket.MemoryUsedPercent += sample.MemoryUsedPercent
+		bucket.Load1 += sample.Load1
+		bucket.NetRecvRate += sample.NetRecvRate
+		bucket.NetSendRate += sample.NetSendRate
+		count++
+	}
+	flush()
+
+	return out
+}
+
+// historyCSVHandler streams the buffered samples as a CSV download. It accepts
+// the same downsampling parameters as /history.
+func (app *application) historyCSVHandler(w http.ResponseWriter, r *http.Request) {
+	samples, ok := app.queryHistory(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=vitals.csv")
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"timestamp", "cpu", "mem%", "load1", "net_recv_rate", "net_send_rate"})
+
+	for _, sample := range samples {
+		out.Write([]string{
+			sample.Timestamp.Format(time.RFC3339),
+			formatCSVFloat(sample.CPUUsage),
+			formatCSVFloat(sample.MemoryUsedPercent),
+			formatCSVFloat(sample.Load1),
+			formatCSVFloat(sample.NetRecvRate),
+			formatCSVFloat(sample.NetSendRate),
+		})
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		slog.Warn("writing history csv", "error", err)
+	}
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// historySweepInterval is how often samples past the retention are deleted
+const historySweepInterval = time.Hour
+
+type historyDBConfig struct {
+	path      string // HISTORY_DB; history is kept in memory only when empty
+	retention time.Duration
+}
+
+// historyDB persists history samples to SQLite so /history survives restarts.
+// Sample IDs are the row IDs, which keep increasing across restarts.
+type historyDB struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS history (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts       INTEGER NOT NULL, -- unix milliseconds
+	cpu      REAL NOT NULL,
+	mem      REAL NOT NULL,
+	load1    REAL NOT NULL,
+	net_recv REAL NOT NULL,
+	net_send REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_ts ON history (ts);
+`
+
+// openHistoryDB opens or creates the database at path
+func openHistoryDB(cfg historyDBConfig) (*historyDB, error) {
+	// WAL lets /history read while the collector writes
+	db, err := sql.Open("sqlite3", "file:"+cfg.path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &historyDB{db: db, retention: cfg.retention}, nil
+}
+
+func (h *historyDB) close() error {
+	return h.db.Close()
+}
+
+// run stores every snapshot the collector publishes and deletes samples past
+// the retention once an hour, until ctx is cancelled
+func (h *historyDB) run(ctx context.Context, c *collector) {
+	updates := c.subscribe()
+	defer c.unsubscribe(updates)
+
+	sweep := time.NewTicker(historySweepInterval)
+	defer sweep.Stop()
+
+	h.sweep(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sweep.C:
+			h.sweep(ctx)
+		case vitals := <-updates:
+			if err := h.insert(ctx, newHistorySample(vitals)); err != nil {
+				slog.Warn("writing history sample failed", "error", err)
+			}
+		}
+	}
+}
+
+func (h *historyDB) insert(ctx context.Context, sample HistorySample) error {
+	_, err := h.db.ExecContext(ctx,
+		"INSERT INTO history (ts, cpu, mem, load1, net_recv, net_send) VALUES (?, ?, ?, ?, ?, ?)",
+		sample.Timestamp.UnixMilli(), sample.CPUUsage, sample.MemoryUsedPercent, sample.Load1,
+		sample.NetRecvRate, sample.NetSendRate,
+	)
+
+	return err
+}
+
+// sweep deletes samples older than the retention
+func (h *historyDB) sweep(ctx context.Context) {
+	cutoff := time.Now().Add(-h.retention).UnixMilli()
+	result, err := h.db.ExecContext(ctx, "DELETE FROM history WHERE ts < ?", cutoff)
+	if err != nil {
+		slog.Warn("deleting expired history failed", "error", err)
+		return
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		slog.Debug("deleted expired history", "samples", n)
+	}
+}
+
+// query returns the samples taken within [from, to], oldest first. A zero
+// bound leaves that side of the range open.
+func (h *historyDB) query(ctx context.Context, from, to time.Time) ([]HistorySample, error) {
+	query := "SELECT id, ts, cpu, mem, load1, net_recv, net_send FROM history WHERE 1=1"
+	args := make([]any, 0, 2)
+	if !from.IsZero() {
+		query += " AND ts >= ?"
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += " AND ts <= ?"
+		args = append(args, to.UnixMilli())
+	}
+	query += " ORDER BY ts, id"
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make([]HistorySample, 0)
+	for rows.Next() {
+		var sample HistorySample
+		var ts int64
+		if err := rows.Scan(&sample.ID, &ts, &sample.CPUUsage, &sample.MemoryUsedPercent, &sample.Load1,
+			&sample.NetRecvRate, &sample.NetSendRate); err != nil {
+			return nil, err
+		}
+		sample.Timestamp = time.UnixMilli(ts)
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+// parseDayDuration parses a Go duration, also accepting whole days such as "7d"
+func parseDayDuration(raw string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type influxConfig struct {
+	url    string
+	token  string
+	org    string
+	bucket string
+}
+
+// enabled reports whether every setting needed to write to InfluxDB is present
+func (c influxConfig) enabled() bool {
+	return c.url != "" && c.token != "" && c.org != "" && c.bucket != ""
+}
+
+// influxExporter writes each collector snapshot to an InfluxDB v2 bucket as
+// line protocol
+type influxExporter struct {
+	writeURL string
+	token    string
+	client   *http.Client
+}
+
+func newInfluxExporter(cfg influxConfig) (*influxExporter, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.url, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", base.Scheme)
+	}
+
+	base.Path += "/api/v2/write"
+	base.RawQuery = url.Values{
+		"org":       {cfg.org},
+		"bucket":    {cfg.bucket},
+		"precision": {"ns"},
+	}.Encode()
+
+	return &influxExporter{
+		writeURL: base.String(),
+		token:    cfg.token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// run writes every snapshot the collector publishes until ctx is cancelled.
+// Snapshots published while a write is still in flight are skipped.
+func (e *influxExporter) run(ctx context.Context, c *collector) {
+	updates := c.subscribe()
+	defer c.unsubscribe(updates)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case vitals := <-updates:
+			if err := e.write(ctx, vitals); err != nil {
+				slog.Warn("influxdb write failed", "error", err)
+			}
+		}
+	}
+}
+
+func (e *influxExporter) write(ctx context.Context, vitals *SystemVitals) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.writeURL, bytes.NewReader(influxLines(vitals)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+e.token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// influxLines encodes the snapshot as line protocol, one measurement per
+// subsystem, tagged with the host name
+func influxLines(vitals *SystemVitals) []byte {
+	hostname := ""
+	if vitals.HostInfo != nil {
+		hostname = vitals.HostInfo.Hostname
+	} else {
+		hostname, _ = os.Hostname()
+	}
+
+	lines := &lineBuilder{
+		host:      hostname,
+		timestamp: vitals.LastUpdated.UnixNano(),
+	}
+
+	lines.add("cpu", nil,
+		"usage_percent", vitals.CPUUsage,
+		"freq_mhz", vitals.CPUFreqMHz,
+	)
+	for i, pct := range vitals.CPUPerCore {
+		lines.add("cpu", []string{"core", strconv.Itoa(i)}, "usage_percent", pct)
+	}
+
+	if vitals.Memory != nil {
+		lines.add("memory", nil,
+			"total", vitals.Memory.Total,
+			"used", vitals.Memory.Used,
+			"available", vitals.Memory.Available,
+			"used_percent", vitals.Memory.UsedPercent,
+			"actual_used_percent", vitals.MemoryActualUsedPercent,
+			"committed", vitals.MemoryCommitted,
+			"commit_limit", vitals.MemoryCommitLimit,
+			"commit_ratio", vitals.CommitRatio,
+		)
+	}
+
+	if vitals.Swap != nil {
+		lines.add("swap", nil,
+			"total", vitals.Swap.Total,
+			"used", vitals.Swap.Used,
+			"used_percent", vitals.Swap.UsedPercent,
+			"in_rate", vitals.SwapInRate,
+			"out_rate", vitals.SwapOutRate,
+		)
+	}
+
+	for _, d := range vitals.Disks {
+		lines.add("disk", []string{"mount", d.MountPoint},
+			"total", d.Total,
+			"used", d.Used,
+			"free", d.Free,
+			"used_percent", d.UsedPercent,
+		)
+	}
+
+	for _, iface := range vitals.NetworkIfaces {
+		lines.add("net", []string{"interface", iface.Name},
+			"bytes_sent", iface.BytesSent,
+			"bytes_recv", iface.BytesRecv,
+		)
+	}
+
+	if vitals.LoadAvg != nil {
+		lines.add("load", nil,
+			"load1", vitals.LoadAvg.Load1,
+			"load5", vitals.LoadAvg.Load5,
+			"load15", vitals.LoadAvg.Load15,
+		)
+	}
+
+	for _, temp := range vitals.Temperature {
+		lines.add("temperature", []string{"sensor", temp.SensorKey}, "celsius", temp.Temperature)
+	}
+
+	lines.add("system", nil,
+		"uptime", vitals.Uptime,
+		"app_uptime", vitals.AppUptime,
+		"processes", vitals.Processes,
+		"updates", vitals.SystemUpdates,
+	)
+
+	return lines.buf.Bytes()
+}
+
+// lineBuilder accumulates line protocol points sharing a host tag and timestamp
+type lineBuilder struct {
+	buf       bytes.Buffer
+	host      string
+	timestamp int64
+}
+
+// add appends a point. tags and fields are alternating keys and values; tag
+// values must be strings, field values float64, int or uint64.
+func (l *lineBuilder) add(measurement string, tags []string, fields ...any) {
+	var fieldSet []string
+	for i := 0; i+1 < len(fields); i += 2 {
+		key := fields[i].(string)
+		switch v := fields[i+1].(type) {
+		case float64:
+			// Line protocol has no representation for NaN or infinity
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				fieldSet = append(fieldSet, key+"="+strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		case int:
+			fieldSet = append(fieldSet, key+"="+strconv.Itoa(v)+"i")
+		case uint64:
+			fieldSet = append(fieldSet, key+"="+strconv.FormatUint(v, 10)+"u")
+		}
+	}
+	if len(fieldSet) == 0 {
+		return
+	}
+
+	l.buf.WriteString(lineEscaper.Replace(measurement))
+	if l.host != "" {
+		l.buf.WriteString(",host=" + lineEscaper.Replace(l.host))
+	}
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] == "" {
+			continue
+		}
+		l.buf.WriteString("," + tags[i] + "=" + lineEscaper.Replace(tags[i+1]))
+	}
+	l.buf.WriteString(" " + strings.Join(fieldSet, ",") + " " + strconv.FormatInt(l.timestamp, 10) + "\n")
+}
+
+// lineEscaper escapes the characters line protocol reserves in measurement
+// names and tag values
+var lineEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	journalctlOnce sync.Once
+	journalctlPath string
+)
+
+func lookupJournalctl() string {
+	journalctlOnce.Do(func() {
+		path, err := exec.LookPath("journalctl")
+		if err != nil {
+			slog.Info("journalctl not found, journal monitoring disabled")
+			return
+		}
+		journalctlPath = path
+	})
+
+	return journalctlPath
+}
+
+// journalTracker keeps the end of the window counted by the previous pass, so
+// each pass counts the entries logged since then exactly once
+type journalTracker struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// collectJournalErrors sets JournalErrorRate to the entries of priority err
+// or worse logged per minute since the previous pass. The first pass only
+// records a baseline. It is a no-op off Linux.
+func collectJournalErrors(ctx context.Context, vitals *SystemVitals, enabled bool, tracker *journalTracker) {
+	if !enabled || runtime.GOOS != "linux" {
+		return
+	}
+
+	path := lookupJournalctl()
+	if path == "" {
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	now := time.Now()
+	if tracker.until.IsZero() {
+		tracker.until = now
+		return
+	}
+
+	// -o cat prints one line per message, like `journalctl ... | wc -l`
+	output, err := exec.CommandContext(ctx, path, "--no-pager", "--quiet", "--priority", "err",
+		"--since", journalTimestamp(tracker.until), "--until", journalTimestamp(now), "--output", "cat").Output()
+	if err != nil {
+		vitals.collectFailed("journal", err)
+		return
+	}
+
+	vitals.JournalErrorRate = float64(bytes.Count(output, []byte("\n"))) / now.Sub(tracker.until).Minutes()
+	tracker.until = now
+}
+
+// journalTimestamp formats t as the "@seconds" form journalctl accepts, to the
+// microsecond
+func journalTimestamp(t time.Time) string {
+	return fmt.Sprintf("@%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
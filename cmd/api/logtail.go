@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+const (
+	// logTailPoll is how often a tailed file is checked for new lines
+	logTailPoll = 500 * time.Millisecond
+
+	// maxLogTailLines caps the ?lines= backfill
+	maxLogTailLines = 1000
+
+	// logTailBackfillBytes is how far from the end of the file the backfill
+	// looks for lines
+	logTailBackfillBytes = 256 << 10
+
+	// maxLogLineBytes splits longer lines, so a file without newlines can't
+	// grow the buffer without bound
+	maxLogLineBytes = 64 << 10
+)
+
+type logTailConfig struct {
+	paths []string // LOGTAIL_PATHS; the endpoint is disabled when empty
+}
+
+// errLogTailNotAllowed is returned by read when the path was replaced by a
+// file, or a symlink to one, outside LOGTAIL_PATHS
+var errLogTailNotAllowed = errors.New("file is no longer within LOGTAIL_PATHS")
+
+// logTailer follows a file by path like `tail -F`, reopening it when it is
+// rotated and starting over when it is truncated
+type logTailer struct {
+	path    string
+	roots   []string // LOGTAIL_PATHS, checked again whenever the file is replaced
+	file    *os.File
+	offset  int64
+	partial []byte // an unterminated last line, completed by a later read
+}
+
+func openLogTailer(path string, roots []string) (*logTailer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logTailer{path: path, roots: roots, file: f}, nil
+}
+
+func (t *logTailer) close() error {
+	return t.file.Close()
+}
+
+// backfill returns up to n of the last complete lines of the file and
+// positions the tailer after them
+func (t *logTailer) backfill(n int) ([]string, error) {
+	info, err := t.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+	t.offset = end
+
+	start := max(end-logTailBackfillBytes, 0)
+	buf := make([]byte, end-start)
+	if _, err := t.file.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	// An unterminated last line is sent once it is complete
+	i := bytes.LastIndexByte(buf, '\n')
+	t.partial = append([]byte(nil), buf[i+1:]...)
+	if n == 0 || i < 0 {
+		return nil, nil
+	}
+
+	lines := bytes.Split(buf[:i], []byte("\n"))
+	if start > 0 {
+		// The first line was cut by the window
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, string(line))
+	}
+
+	return out, nil
+}
+
+// read returns the complete lines written since the last read. rotated is set
+// once the old file is drained when the path now names a different file, which
+// is then followed from its start. A replacement outside the allowed roots
+// returns errLogTailNotAllowed along with the old file's last lines.
+func (t *logTailer) read() (lines []string, rotated bool, err error) {
+	info, err := t.file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() < t.offset {
+		// Truncated in place, e.g. by copytruncate
+		t.offset = 0
+		t.partial = nil
+	}
+
+	lines, err = t.readLines()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// A missing path is usually a rotation in progress; keep the old file
+	// until the new one appears
+	current, err := os.Stat(t.path)
+	if err != nil || os.SameFile(info, current) {
+		return lines, false, nil
+	}
+
+	// The new file may be a symlink out of the allowed roots
+	resolved, err := allowedPath(t.path, t.roots, "LOGTAIL_PATHS")
+	if errors.Is(err, fs.ErrNotExist) {
+		return lines, false, nil
+	}
+	if err != nil {
+		return lines, false, errLogTailNotAllowed
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return lines, false, nil
+	}
+	t.file.Close()
+	t.file, t.offset, t.partial = f, 0, nil
+
+	return lines, true, nil
+}
+
+func (t *logTailer) readLines() ([]string, error) {
+	lines := make([]string, 0)
+	buf := make([]byte, 32<<10)
+
+	for {
+		n, err := t.file.ReadAt(buf, t.offset)
+		t.offset += int64(n)
+		data := append(t.partial, buf[:n]...)
+
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			lines = append(lines, string(data[:i]))
+			data = data[i+1:]
+		}
+		if len(data) >= maxLogLineBytes {
+			lines = append(lines, string(data))
+			data = nil
+		}
+		t.partial = append([]byte(nil), data...)
+
+		if errors.Is(err, io.EOF) || n == 0 {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// logTailHandler streams lines appended to ?file= as SSE `data:` events, like
+// `tail -F`. ?lines=N first sends up to the last N lines. A `rotated` event is
+// sent when the file is replaced and the new one is followed from its start.
+// The file must be within one of LOGTAIL_PATHS.
+func (app *application) logTailHandler(w http.ResponseWriter, r *http.Request) {
+	if len(app.config.logTail.paths) == 0 {
+		app.forbiddenResponse(w, r, errors.New("endpoint requires LOGTAIL_PATHS to be configured"))
+		return
+	}
+
+	backfill := 0
+	if raw := r.URL.Query().Get("lines"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxLogTailLines {
+			app.badRequestResponse(w, r, fmt.Errorf("lines must be between 0 and %d", maxLogTailLines))
+			return
+		}
+		backfill = n
+	}
+
+	path, err := allowedPath(r.URL.Query().Get("file"), app.config.logTail.paths, "LOGTAIL_PATHS")
+	if errors.Is(err, fs.ErrNotExist) {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		app.badRequestResponse(w, r, fmt.Errorf("file %q is not a regular file", path))
+		return
+	}
+
+	tailer, err := openLogTailer(path, app.config.logTail.paths)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer tailer.close()
+
+	lines, err := tailer.backfill(backfill)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	app.sseConnected.Add(1)
+	defer app.sseConnected.Add(-1)
+
+	slog.Info("tailing log file", "file", path, "requestId", middleware.GetReqID(r.Context()))
+	defer slog.Info("stopped tailing log file", "file", path, "requestId", middleware.GetReqID(r.Context()))
+
+	sendLogLines(w, flusher, lines)
+
+	poll := time.NewTicker(logTailPoll)
+	defer poll.Stop()
+
+	keepAlive := time.NewTicker(app.config.sse.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.shutdown:
+			fmt.Fprint(w, "event: close\ndata: server closing\n\n")
+			flusher.Flush()
+			return
+		case <-poll.C:
+			lines, rotated, err := tailer.read()
+			sendLogLines(w, flusher, lines)
+			if errors.Is(err, errLogTailNotAllowed) {
+				slog.Warn("tailed log file was replaced by one outside LOGTAIL_PATHS", "file", path)
+				fmt.Fprintf(w, "event: close\ndata: %s\n\n", errLogTailNotAllowed)
+				flusher.Flush()
+				return
+			}
+			if err != nil {
+				slog.Warn("reading tailed log file failed", "file", path, "error", err)
+				fmt.Fprint(w, "event: close\ndata: read failed\n\n")
+				flusher.Flush()
+				return
+			}
+			if rotated {
+				fmt.Fprintf(w, "event: rotated\ndata: %s\n\n", path)
+				flusher.Flush()
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// sendLogLines writes each line as its own SSE event. Carriage returns are
+// dropped, since SSE treats them as line breaks.
+func sendLogLines(w io.Writer, flusher http.Flusher, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(line, "\r", ""))
+	}
+	flusher.Flush()
+}
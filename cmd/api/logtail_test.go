@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLogTailerRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		rotate      func(t *testing.T, path, outside string)
+		wantRotated bool
+		wantErr     error
+	}{
+		{
+			name: "new file",
+			rotate: func(t *testing.T, path, outside string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("fresh\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantRotated: true,
+		},
+		{
+			name: "symlink out of the allowed roots",
+			rotate: func(t *testing.T, path, outside string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(outside, path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: errLogTailNotAllowed,
+		},
+		{
+			name: "not yet recreated",
+			rotate: func(t *testing.T, path, outside string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			allowed := filepath.Join(root, "logs")
+			if err := os.Mkdir(allowed, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			outside := filepath.Join(root, "secret")
+			if err := os.WriteFile(outside, []byte("secret\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(allowed, "app.log")
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tailer, err := openLogTailer(path, []string{allowed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tailer.close()
+
+			// Lines written before the rotation are still delivered
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString("last\n")
+			f.Close()
+			tt.rotate(t, path, outside)
+
+			lines, rotated, err := tailer.read()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if rotated != tt.wantRotated {
+				t.Errorf("rotated = %t, want %t", rotated, tt.wantRotated)
+			}
+			if !slices.Equal(lines, []string{"last"}) {
+				t.Errorf("lines = %q, want %q", lines, []string{"last"})
+			}
+
+			lines, _, _ = tailer.read()
+			if slices.Contains(lines, "secret") {
+				t.Errorf("read the file outside the allowed roots: %q", lines)
+			}
+		})
+	}
+}
@@ -1,41 +1,505 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/RakibulBh/homeserver-vitals/internal/env"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	startTime := time.Now()
+
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found or could not be loaded: %v", err)
+	dotenvErr := godotenv.Load()
+
+	// Logger
+	level, err := parseLogLevel(env.GetString("LOG_LEVEL", "info"))
+	slog.SetDefault(newLogger(level))
+	if err != nil {
+		slog.Warn("invalid LOG_LEVEL, using info", "error", err)
 	}
 
-	environment := env.GetString("ENV", "development")
-	if environment != "development" {
-		log.Printf("Running in %s environment", environment)
-	} else {
-		log.Printf("Running in development environment")
+	if dotenvErr != nil {
+		slog.Warn(".env file not found or could not be loaded", "error", dotenvErr)
+	}
+
+	// Flags take precedence over environment variables, which take precedence
+	// over the optional config file
+	configPath := flag.String("config", env.GetString("CONFIG_FILE", ""), "path to a YAML or JSON config file")
+	addrFlag := flag.String("addr", "", "address to listen on, e.g. :9090 (overrides PORT)")
+	envFlag := flag.String("env", "", "environment name, e.g. production (overrides ENV)")
+	flag.Parse()
+
+	file, err := loadConfigFile(*configPath)
+	if err != nil {
+		fatal("loading config file", "path", *configPath, "error", err)
+	}
+
+	environment := env.GetString("ENV", file.Env)
+	if *envFlag != "" {
+		environment = *envFlag
+	}
+
+	info := buildInfo()
+	slog.Info("running",
+		"environment", environment,
+		"version", info.Version,
+		"commit", info.Commit,
+		"buildDate", info.BuildDate,
+		"goVersion", info.GoVersion,
+	)
+
+	addr := file.Addr
+	if port := env.GetString("PORT", ""); port != "" {
+		addr = ":" + port
+	}
+	if *addrFlag != "" {
+		addr = *addrFlag
 	}
 
 	// Load configuration
 	cfg := config{
-		addr: ":" + env.GetString("PORT", "2000"),
+		addr: addr,
 		env:  environment,
+		auth: authConfig{
+			token:    env.GetString("API_TOKEN", ""),
+			keysFile: env.GetString("API_KEYS_FILE", ""),
+		},
+		tls: tlsConfig{
+			certFile: env.GetString("TLS_CERT_FILE", file.TLS.CertFile),
+			keyFile:  env.GetString("TLS_KEY_FILE", file.TLS.KeyFile),
+		},
+		sse: sseConfig{
+			keepAlive:     env.GetDuration("SSE_KEEPALIVE", 15*time.Second),
+			maxPerIP:      env.GetInt("MAX_SSE_PER_IP", 3),
+			keyframeEvery: env.GetInt("SSE_DELTA_KEYFRAME", 12),
+		},
+		influx: influxConfig{
+			url:    env.GetString("INFLUXDB_URL", ""),
+			token:  env.GetString("INFLUXDB_TOKEN", ""),
+			org:    env.GetString("INFLUXDB_ORG", ""),
+			bucket: env.GetString("INFLUXDB_BUCKET", ""),
+		},
+		otel: otelConfig{
+			endpoint: env.GetString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		},
+		diskUsage: diskUsageConfig{
+			roots:      parsePatterns(env.GetString("DISKUSAGE_ROOTS", "")),
+			timeout:    env.GetDuration("DISKUSAGE_TIMEOUT", 30*time.Second),
+			maxEntries: env.GetInt("DISKUSAGE_MAX_ENTRIES", 1000000),
+		},
+		logTail: logTailConfig{
+			paths: parsePatterns(env.GetString("LOGTAIL_PATHS", "")),
+		},
+		historyDB: historyDBConfig{
+			path: env.GetString("HISTORY_DB", ""),
+		},
+		agent: agentConfig{
+			url:   env.GetString("AGGREGATOR_URL", ""),
+			token: env.GetString("AGGREGATOR_TOKEN", ""),
+			name:  env.GetString("AGENT_NAME", ""),
+		},
+		aggregator: aggregatorConfig{
+			token: env.GetString("INGEST_TOKEN", env.GetString("API_TOKEN", "")),
+		},
+		snapshot: snapshotConfig{
+			path:     env.GetString("SNAPSHOT_FILE", ""),
+			mode:     env.GetString("SNAPSHOT_MODE", snapshotModeAppend),
+			maxBytes: int64(env.GetInt("SNAPSHOT_MAX_MB", 100)) << 20,
+		},
+		collector: collectorConfig{
+			interval:       env.GetDuration("COLLECT_INTERVAL", file.Interval),
+			timeout:        env.GetDuration("COLLECT_TIMEOUT", 4*time.Second),
+			updateInterval: env.GetDuration("UPDATE_CHECK_INTERVAL", time.Hour),
+			options: collectOptions{
+				cpuSample: env.GetDuration("CPU_SAMPLE_INTERVAL", time.Second),
+				interfaces: newNameFilter(
+					env.GetString("NET_INTERFACE_INCLUDE", ""),
+					env.GetString("NET_INTERFACE_EXCLUDE", "lo,docker*,veth*,br-*"),
+				),
+				disks: diskOptions{
+					mounts: newNameFilter(
+						env.GetString("DISK_MOUNT_INCLUDE", ""),
+						env.GetString("DISK_MOUNT_EXCLUDE", ""),
+					),
+					pseudoFS: env.GetBool("DISK_PSEUDO_FS", false),
+					io:       newNameFilter("", env.GetString("DISK_IO_EXCLUDE", "loop*,ram*,dm-*")),
+				},
+				processes: processOptions{
+					sortBy:     env.GetString("PROCESS_SORT", processSortCPU),
+					limit:      env.GetInt("TOP_PROCESSES", 5),
+					cpuMin:     env.GetFloat64("PROCESS_CPU_MIN", 0),
+					memMin:     env.GetFloat64("PROCESS_MEM_MIN", 0),
+					cmdlineMax: env.GetInt("CMDLINE_MAX", 256),
+				},
+				docker: dockerOptions{
+					enabled: env.GetBool("DOCKER_METRICS", false),
+					socket:  env.GetString("DOCKER_SOCKET", "/var/run/docker.sock"),
+				},
+				smart:    env.GetBool("SMART_MONITORING", false),
+				zfs:      env.GetBool("ZFS_MONITORING", false),
+				raid:     env.GetBool("RAID_MONITORING", false),
+				journal:  env.GetBool("JOURNAL_MONITORING", false),
+				services: parsePatterns(env.GetString("MONITOR_SERVICES", "")),
+				connectivity: connectivityOptions{
+					enabled:     env.GetBool("CONNECTIVITY_MONITORING", false),
+					publicIPURL: env.GetString("PUBLIC_IP_URL", "https://api.ipify.org"),
+					publicIPTTL: env.GetDuration("PUBLIC_IP_TTL", 10*time.Minute),
+					gateway:     env.GetString("GATEWAY_ADDR", ""),
+					gatewayPort: env.GetString("GATEWAY_PORT", "53"),
+					internet:    env.GetString("INTERNET_CHECK_ADDR", "1.1.1.1:53"),
+					timeout:     env.GetDuration("CONNECTIVITY_TIMEOUT", 3*time.Second),
+				},
+				ping: pingOptions{
+					targets: parsePatterns(env.GetString("PING_TARGETS", "")),
+					count:   env.GetInt("PING_COUNT", 3),
+					timeout: env.GetDuration("PING_TIMEOUT", time.Second),
+					tcpPort: env.GetString("PING_TCP_PORT", "80"),
+				},
+			},
+			historySize: env.GetInt("HISTORY_SIZE", 720),
+			thresholds:  loadThresholds(file.thresholds()),
+			alerts: alertConfig{
+				webhookURL:    env.GetString("ALERT_WEBHOOK_URL", ""),
+				webhookFormat: env.GetString("ALERT_WEBHOOK_FORMAT", webhookFormatRaw),
+				email: emailConfig{
+					host:    env.GetString("SMTP_HOST", ""),
+					port:    env.GetInt("SMTP_PORT", 587),
+					user:    env.GetString("SMTP_USER", ""),
+					pass:    env.GetString("SMTP_PASS", ""),
+					from:    env.GetString("ALERT_EMAIL_FROM", ""),
+					to:      parsePatterns(env.GetString("ALERT_EMAIL_TO", "")),
+					perHour: env.GetInt("ALERT_EMAIL_PER_HOUR", 10),
+				},
+				consecutive: env.GetInt("ALERT_CONSECUTIVE", 3),
+			},
+		},
+	}
+
+	origins, err := parseOrigins(env.GetString("FRONTEND_URL", file.FrontendURL))
+	if err != nil {
+		fatal("invalid FRONTEND_URL", "error", err)
+	}
+	cfg.origins = origins
+	slog.Info("allowed CORS origins", "origins", origins.String())
+
+	mode, err := parseMode(env.GetString("MODE", modeServer))
+	if err != nil {
+		fatal("invalid MODE", "error", err)
+	}
+	cfg.mode = mode
+
+	if cfg.mode == modeAgent && !cfg.agent.enabled() {
+		fatal("MODE=agent requires AGGREGATOR_URL")
+	}
+
+	if cfg.mode == modeAggregator && cfg.aggregator.token == "" {
+		fatal("MODE=aggregator requires INGEST_TOKEN or API_TOKEN so agents must authenticate")
+	}
+
+	if cfg.agent.name == "" {
+		cfg.agent.name, _ = os.Hostname()
+	}
+
+	if raw := env.GetString("TEMPERATURE_ENABLED", ""); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			fatal("invalid TEMPERATURE_ENABLED, must be true or false")
+		}
+		cfg.collector.options.temperature = &enabled
+	}
+
+	tempUnit, err := parseTempUnit(env.GetString("TEMP_UNIT", tempUnitCelsius))
+	if err != nil {
+		fatal("invalid TEMP_UNIT", "error", err)
+	}
+	cfg.tempUnit = tempUnit
+
+	if cfg.sse.keepAlive <= 0 {
+		fatal("invalid SSE_KEEPALIVE, must be a positive duration")
+	}
+
+	if cfg.sse.maxPerIP < 1 {
+		fatal("invalid MAX_SSE_PER_IP, must be at least 1")
+	}
+
+	if cfg.sse.keyframeEvery < 1 {
+		fatal("invalid SSE_DELTA_KEYFRAME, must be at least 1")
+	}
+
+	commandTimeout = env.GetDuration("COMMAND_TIMEOUT", commandTimeout)
+	if commandTimeout <= 0 {
+		fatal("invalid COMMAND_TIMEOUT, must be a positive duration")
 	}
 
+	retention, err := parseDayDuration(env.GetString("HISTORY_RETENTION", "7d"))
+	if err != nil {
+		fatal("invalid HISTORY_RETENTION", "error", err)
+	}
+	cfg.historyDB.retention = retention
+
+	if cfg.diskUsage.timeout <= 0 {
+		fatal("invalid DISKUSAGE_TIMEOUT, must be a positive duration")
+	}
+
+	if cfg.diskUsage.maxEntries < 1 {
+		fatal("invalid DISKUSAGE_MAX_ENTRIES, must be at least 1")
+	}
+
+	for _, root := range cfg.diskUsage.roots {
+		if !filepath.IsAbs(root) {
+			fatal("invalid DISKUSAGE_ROOTS, paths must be absolute", "root", root)
+		}
+	}
+
+	if cfg.collector.options.processes.cpuMin < 0 || cfg.collector.options.processes.memMin < 0 {
+		fatal("invalid PROCESS_CPU_MIN or PROCESS_MEM_MIN, must be 0 or more")
+	}
+
+	if cfg.collector.options.processes.cmdlineMax < 0 {
+		fatal("invalid CMDLINE_MAX, must be 0 or more")
+	}
+
+	if cfg.collector.options.connectivity.timeout <= 0 {
+		fatal("invalid CONNECTIVITY_TIMEOUT, must be a positive duration")
+	}
+
+	if cfg.collector.options.ping.count < 1 {
+		fatal("invalid PING_COUNT, must be at least 1")
+	}
+
+	if cfg.collector.options.ping.timeout <= 0 {
+		fatal("invalid PING_TIMEOUT, must be a positive duration")
+	}
+
+	// Unset variables fall back to the defaults, so "none" turns a probe off
+	connectivity := &cfg.collector.options.connectivity
+	if strings.EqualFold(connectivity.publicIPURL, "none") {
+		connectivity.publicIPURL = ""
+	}
+	if strings.EqualFold(connectivity.internet, "none") {
+		connectivity.internet = ""
+	}
+
+	for _, path := range cfg.logTail.paths {
+		if !filepath.IsAbs(path) {
+			fatal("invalid LOGTAIL_PATHS, paths must be absolute", "path", path)
+		}
+	}
+
+	// Also set by interval in the config file
+	if cfg.collector.interval <= 0 {
+		fatal("invalid COLLECT_INTERVAL, must be a positive duration")
+	}
+
+	if cfg.collector.timeout <= 0 {
+		fatal("invalid COLLECT_TIMEOUT, must be a positive duration")
+	}
+
+	// Slow-changing metric groups can be collected less often than snapshots
+	// are published
+	cfg.collector.groupIntervals = make(map[string]time.Duration, len(metricGroups))
+	for _, group := range metricGroups {
+		interval := env.GetDuration(group.env, max(group.interval, cfg.collector.interval))
+		if interval < cfg.collector.interval {
+			fatal("invalid "+group.env+", must be at least COLLECT_INTERVAL", "value", interval.String(), "collectInterval", cfg.collector.interval.String())
+		}
+		if interval > cfg.collector.interval {
+			slog.Info("collecting metric group on its own interval", "group", group.name, "interval", interval.String())
+		}
+		cfg.collector.groupIntervals[group.name] = interval
+	}
+
+	if cfg.collector.updateInterval <= 0 {
+		fatal("invalid UPDATE_CHECK_INTERVAL, must be a positive duration")
+	}
+
+	if limit := cfg.collector.options.processes.limit; limit < 1 || limit > maxTopProcesses {
+		fatal("invalid TOP_PROCESSES", "value", limit, "min", 1, "max", maxTopProcesses)
+	}
+
+	if err := validateTLSConfig(cfg.tls); err != nil {
+		fatal("invalid TLS configuration", "error", err)
+	}
+
+	// Alert notifications
+	var sinks []alertSink
+	if cfg.collector.alerts.webhookURL != "" {
+		format, err := parseWebhookFormat(cfg.collector.alerts.webhookFormat)
+		if err != nil {
+			fatal("invalid ALERT_WEBHOOK_FORMAT", "error", err)
+		}
+		sinks = append(sinks, newWebhookSink(cfg.collector.alerts.webhookURL, format))
+	}
+	if email := cfg.collector.alerts.email; email.enabled() {
+		if email.perHour < 1 {
+			fatal("invalid ALERT_EMAIL_PER_HOUR, must be at least 1")
+		}
+		email.implicit = email.port == 465
+		if email.from == "" {
+			email.from = defaultEmailFrom(email.user)
+		}
+		slog.Info("emailing alerts", "host", email.host, "port", email.port, "to", strings.Join(email.to, ","))
+		sinks = append(sinks, newEmailSink(email))
+	} else if email.host != "" || len(email.to) > 0 {
+		slog.Warn("SMTP_HOST and ALERT_EMAIL_TO must both be set, alert emails disabled")
+	}
+
+	var notifier *alertNotifier
+	if len(sinks) > 0 {
+		notifier = newAlertNotifier(cfg.collector.alerts.consecutive, sinks...)
+	}
+
+	src := hostSource{}
 	app := &application{
-		config: cfg,
+		config:     cfg,
+		startTime:  startTime,
+		source:     src,
+		collector:  newCollector(cfg.collector, src, notifier, startTime),
+		sseClients: newSSELimiter(cfg.sse.maxPerIP),
+		shutdown:   make(chan struct{}),
+	}
+	if cfg.mode == modeAggregator {
+		app.hosts = newHostRegistry()
 	}
 
-	// Prepare server
-	log.Printf("Setting up HTTP server on %s", cfg.addr)
-	mux := app.serve()
+	// Scoped API keys, reloaded from the file on SIGHUP
+	if cfg.auth.keysFile != "" {
+		app.keys, err = loadAPIKeys(cfg.auth.keysFile)
+		if err != nil {
+			fatal("loading API_KEYS_FILE", "path", cfg.auth.keysFile, "error", err)
+		}
+		slog.Info("loaded API keys", "path", cfg.auth.keysFile, "keys", app.keys.len())
+		go reloadKeysOnHangup(app.keys)
+	}
+
+	if !app.authEnabled() {
+		slog.Warn("API_TOKEN and API_KEYS_FILE are not set, metric endpoints are unauthenticated")
+	}
 
-	log.Fatal(app.run(mux))
+	// Background work stops on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Static hardware details are looked up once
+	cachedHardwareInfo()
+
+	// Without TEMPERATURE_ENABLED, sensors are only read when some were found
+	if cfg.collector.options.temperature == nil {
+		probeTemperatureSensors()
+	}
+
+	// Optional export of every snapshot to InfluxDB
+	if cfg.influx.enabled() {
+		exporter, err := newInfluxExporter(cfg.influx)
+		if err != nil {
+			fatal("invalid INFLUXDB_URL", "error", err)
+		}
+		slog.Info("exporting to influxdb", "url", cfg.influx.url, "bucket", cfg.influx.bucket)
+		go exporter.run(ctx, app.collector)
+	} else if cfg.influx.url != "" {
+		slog.Warn("INFLUXDB_URL is set without INFLUXDB_TOKEN, INFLUXDB_ORG and INFLUXDB_BUCKET, export disabled")
+	}
+
+	// Optional OpenTelemetry metrics and request traces
+	if cfg.otel.enabled() {
+		app.telemetry, err = newTelemetry(ctx, app.collector, info.Version)
+		if err != nil {
+			fatal("setting up OpenTelemetry export", "error", err)
+		}
+		slog.Info("exporting to OpenTelemetry", "endpoint", cfg.otel.endpoint)
+	}
+
+	// Optional history persisted across restarts
+	if cfg.historyDB.path != "" {
+		app.historyDB, err = openHistoryDB(cfg.historyDB)
+		if err != nil {
+			fatal("opening HISTORY_DB", "path", cfg.historyDB.path, "error", err)
+		}
+		defer app.historyDB.close()
+		slog.Info("persisting history", "path", cfg.historyDB.path, "retention", cfg.historyDB.retention.String())
+		go app.historyDB.run(ctx, app.collector)
+	}
+
+	// Optional push of every snapshot to a central aggregator
+	if cfg.agent.enabled() {
+		pusher, err := newAgentPusher(cfg.agent, cfg.collector.interval)
+		if err != nil {
+			fatal("invalid AGGREGATOR_URL", "error", err)
+		}
+		slog.Info("pushing to aggregator", "url", cfg.agent.url, "name", cfg.agent.name)
+		go pusher.run(ctx, app.collector)
+	}
+
+	// Optional snapshot file
+	if cfg.snapshot.path != "" {
+		writer, err := newSnapshotWriter(cfg.snapshot)
+		if err != nil {
+			fatal("invalid SNAPSHOT_MODE", "error", err)
+		}
+		slog.Info("writing snapshots", "path", cfg.snapshot.path, "mode", cfg.snapshot.mode)
+		go writer.run(ctx, app.collector)
+	}
+
+	// Package manager queries are slow, so available updates are counted on
+	// their own schedule
+	go runUpdateChecker(ctx, cfg.collector.updateInterval)
+
+	// Start background collection shared by all clients
+	go app.collector.run(ctx)
+
+	if cfg.mode == modeAgent {
+		// Agents only push, so there is nothing to serve
+		<-ctx.Done()
+	} else {
+		// Prepare server
+		slog.Info("setting up HTTP server", "addr", cfg.addr)
+		mux := app.serve()
+
+		if err := app.run(ctx, mux); err != nil {
+			fatal("server stopped", "error", err)
+		}
+	}
+
+	// Flush metrics and spans still buffered for export
+	if app.telemetry != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := app.telemetry.shutdown(flushCtx); err != nil {
+			slog.Warn("flushing OpenTelemetry export", "error", err)
+		}
+		cancel()
+	}
+
+	slog.Info("server stopped")
+}
+
+// validateTLSConfig ensures the certificate and key are either both unset or
+// both point at readable files
+func validateTLSConfig(cfg tlsConfig) error {
+	if cfg.certFile == "" && cfg.keyFile == "" {
+		return nil
+	}
+
+	if cfg.certFile == "" || cfg.keyFile == "" {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	for _, path := range []string{cfg.certFile, cfg.keyFile} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("cannot access %s: %w", path, err)
+		}
+	}
 
-	// Start listening for requests
-	log.Printf("Starting HTTP server, listening on %s", cfg.addr)
+	return nil
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+)
+
+// cpuQuickSample is the sampling window used by the standalone /cpu endpoint
+const cpuQuickSample = 250 * time.Millisecond
+
+type cpuResponse struct {
+	CPUUsage    float64   `json:"cpuUsage"`
+	CPUPerCore  []float64 `json:"cpuPerCore"`
+	FreqMHz     float64   `json:"freqMHz"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+type memoryResponse struct {
+	Memory            *mem.VirtualMemoryStat `json:"memory"`
+	ActualUsedPercent float64                `json:"actualUsedPercent"`
+	Committed         uint64                 `json:"committed"`
+	CommitLimit       uint64                 `json:"commitLimit"`
+	CommitRatio       float64                `json:"commitRatio"`
+	Swap              *mem.SwapMemoryStat    `json:"swap"`
+	LastUpdated       time.Time              `json:"lastUpdated"`
+}
+
+type diskResponse struct {
+	Disks       []DiskInfo            `json:"disks"`
+	DiskIO      map[string]DiskIOStat `json:"diskIO"`
+	LastUpdated time.Time             `json:"lastUpdated"`
+}
+
+type networkResponse struct {
+	Network       net.IOCountersStat `json:"network"`
+	NetworkIfaces []NetworkInterface `json:"networkIfaces"`
+	LastUpdated   time.Time          `json:"lastUpdated"`
+}
+
+type loadResponse struct {
+	LoadAvg     *load.AvgStat `json:"loadAvg"`
+	LoadPerCore float64       `json:"loadPerCore"`
+	LastUpdated time.Time     `json:"lastUpdated"`
+}
+
+// cpuHandler samples CPU usage over cpuQuickSample. With ?blocking=false it
+// answers at once with the usage from the collector's last CPU times delta,
+// falling back to sampling until the collector has one.
+func (app *application) cpuHandler(w http.ResponseWriter, r *http.Request) {
+	if raw := r.URL.Query().Get("blocking"); raw != "" {
+		blocking, err := strconv.ParseBool(raw)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("blocking must be true or false"))
+			return
+		}
+
+		if latest := app.collector.snapshot(); !blocking && latest != nil {
+			if usage, perCore, ok := cpuUsageFromTimes(latest); ok {
+				app.writeMetric(w, &cpuResponse{
+					CPUUsage:    usage,
+					CPUPerCore:  perCore,
+					FreqMHz:     latest.CPUFreqMHz,
+					LastUpdated: latest.LastUpdated,
+				})
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectCPU(ctx, app.source, vitals, cpuQuickSample)
+	collectCPUFreq(ctx, vitals)
+
+	app.writeMetric(w, &cpuResponse{
+		CPUUsage:    vitals.CPUUsage,
+		CPUPerCore:  vitals.CPUPerCore,
+		FreqMHz:     vitals.CPUFreqMHz,
+		LastUpdated: vitals.LastUpdated,
+	})
+}
+
+func (app *application) memoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectMemory(ctx, app.source, vitals)
+
+	app.writeMetric(w, &memoryResponse{
+		Memory:            vitals.Memory,
+		ActualUsedPercent: vitals.MemoryActualUsedPercent,
+		Committed:         vitals.MemoryCommitted,
+		CommitLimit:       vitals.MemoryCommitLimit,
+		CommitRatio:       vitals.CommitRatio,
+		Swap:              vitals.Swap,
+		LastUpdated:       vitals.LastUpdated,
+	})
+}
+
+func (app *application) diskHandler(w http.ResponseWriter, r *http.Request) {
+	// Bounded like a collection pass, so a hung network mount can't hold the
+	// request open
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectDisks(ctx, app.source, vitals, app.config.collector.options.disks)
+
+	app.writeMetric(w, &diskResponse{
+		Disks:       vitals.Disks,
+		DiskIO:      vitals.DiskIO,
+		LastUpdated: vitals.LastUpdated,
+	})
+}
+
+func (app *application) networkHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectNetwork(ctx, app.source, vitals, app.config.collector.options.interfaces, newInterfaceTracker())
+
+	app.writeMetric(w, &networkResponse{
+		Network:       vitals.Network,
+		NetworkIfaces: vitals.NetworkIfaces,
+		LastUpdated:   vitals.LastUpdated,
+	})
+}
+
+func (app *application) loadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectLoad(ctx, app.source, vitals)
+
+	app.writeMetric(w, &loadResponse{
+		LoadAvg:     vitals.LoadAvg,
+		LoadPerCore: vitals.LoadPerCore,
+		LastUpdated: vitals.LastUpdated,
+	})
+}
+
+func (app *application) writeMetric(w http.ResponseWriter, data any) {
+	if err := writeJSON(w, http.StatusOK, data); err != nil {
+		slog.Error("writing metric response", "error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// scopeContextKey carries the authenticated key's scope in the request context
+type scopeContextKey struct{}
+
+// authEnabled reports whether API_TOKEN or API_KEYS_FILE is configured
+func (app *application) authEnabled() bool {
+	return app.config.auth.token != "" || app.keys != nil
+}
+
+// keyScope returns the scope granted to a key: API_TOKEN is an admin key, and
+// keys from API_KEYS_FILE carry their own scope. Unknown keys get "".
+func (app *application) keyScope(key string) string {
+	if app.config.auth.token != "" && subtle.ConstantTimeCompare([]byte(key), []byte(app.config.auth.token)) == 1 {
+		return scopeAdmin
+	}
+
+	if app.keys != nil {
+		return app.keys.scope(key)
+	}
+
+	return ""
+}
+
+// tokenAuthMiddleware requires an `Authorization: Bearer <key>` header with a
+// known key when authentication is configured, and records the key's scope.
+// With no keys configured every request passes.
+func (app *application) tokenAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			app.unauthorizedErrorResponse(w, r, errors.New("authorization header is missing"))
+			return
+		}
+
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			app.unauthorizedErrorResponse(w, r, errors.New("authorization header is malformed"))
+			return
+		}
+
+		scope := app.keyScope(strings.TrimSpace(parts[1]))
+		if scope == "" {
+			app.unauthorizedErrorResponse(w, r, errors.New("invalid token"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeContextKey{}, scope)))
+	})
+}
+
+// requireAdminMiddleware only lets admin keys through. It guards destructive
+// endpoints, which are refused outright when no keys are configured since
+// they must never be left open.
+func (app *application) requireAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authEnabled() {
+			app.forbiddenResponse(w, r, errors.New("endpoint requires API_TOKEN or API_KEYS_FILE to be configured"))
+			return
+		}
+
+		if scope, _ := r.Context().Value(scopeContextKey{}).(string); scope != scopeAdmin {
+			app.forbiddenResponse(w, r, errors.New("endpoint requires an admin key"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodingMsgpack selects MessagePack payloads, keyed like the JSON ones
+const encodingMsgpack = "msgpack"
+
+// marshalMsgpack encodes v as MessagePack using its json tags, so the field
+// names and omitted fields match the JSON payload
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeMsgpack(w http.ResponseWriter, status int, data any) error {
+	payload, err := marshalMsgpack(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(status)
+	_, err = w.Write(payload)
+	return err
+}
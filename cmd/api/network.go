@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+// interfaceTracker keeps each interface's previous counters so error and drop
+// rates can be derived between collection passes
+type interfaceTracker struct {
+	mu   sync.Mutex
+	prev map[string]net.IOCountersStat
+	at   time.Time
+}
+
+func newInterfaceTracker() *interfaceTracker {
+	return &interfaceTracker{
+		prev: make(map[string]net.IOCountersStat),
+	}
+}
+
+// setRates fills in the per-second error and drop rates of each interface from
+// its counters at the given time, and returns the received and sent bytes per
+// second across all of them. Interfaces seen for the first time report zero
+// rates.
+func (t *interfaceTracker) setRates(ifaces []NetworkInterface, counters map[string]net.IOCountersStat, now time.Time) (recvRate, sendRate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := now.Sub(t.at).Seconds()
+	for name, cur := range counters {
+		if prev, ok := t.prev[name]; ok {
+			recvRate += counterRate(prev.BytesRecv, cur.BytesRecv, elapsed)
+			sendRate += counterRate(prev.BytesSent, cur.BytesSent, elapsed)
+		}
+	}
+
+	for i := range ifaces {
+		cur := counters[ifaces[i].Name]
+		prev, ok := t.prev[ifaces[i].Name]
+		if !ok {
+			continue
+		}
+
+		ifaces[i].ErrInRate = counterRate(prev.Errin, cur.Errin, elapsed)
+		ifaces[i].ErrOutRate = counterRate(prev.Errout, cur.Errout, elapsed)
+		ifaces[i].DropInRate = counterRate(prev.Dropin, cur.Dropin, elapsed)
+		ifaces[i].DropOutRate = counterRate(prev.Dropout, cur.Dropout, elapsed)
+	}
+
+	t.prev = counters
+	t.at = now
+
+	return recvRate, sendRate
+}
+
+// linkSpeed returns an interface's negotiated speed in Mbps from sysfs. Virtual
+// interfaces report -1 or fail the read, as do links that are down, and other
+// platforms have no sysfs; all of these are unknown and return 0.
+func linkSpeed(name string) int64 {
+	speed, err := strconv.ParseInt(readSysString(filepath.Join("/sys/class/net", name, "speed")), 10, 64)
+	if err != nil || speed <= 0 {
+		return 0
+	}
+
+	return speed
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+func TestInterfaceTrackerSetRates(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type pass struct {
+		counters map[string]net.IOCountersStat
+		after    time.Duration // since the previous pass
+
+		wantRecv, wantSent float64
+		wantErrIn          map[string]float64 // per interface
+	}
+
+	tests := []struct {
+		name   string
+		passes []pass
+	}{
+		{
+			name: "first sample has no rates",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 1000, BytesSent: 500, Errin: 3}}, wantErrIn: map[string]float64{"eth0": 0}},
+			},
+		},
+		{
+			name: "rates are per second between passes",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 1000, BytesSent: 500}}},
+				{
+					counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 3000, BytesSent: 1500, Errin: 10}},
+					after:    2 * time.Second,
+					wantRecv: 1000, wantSent: 500,
+					wantErrIn: map[string]float64{"eth0": 5},
+				},
+			},
+		},
+		{
+			name: "rates add up across interfaces",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 0}, "eth1": {BytesRecv: 0}}},
+				{
+					counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 100}, "eth1": {BytesRecv: 300}},
+					after:    time.Second,
+					wantRecv: 400,
+				},
+			},
+		},
+		{
+			name: "wrapped counter reports zero",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 1<<32 - 100, BytesSent: 1000, Errin: 50}}},
+				{
+					counters:  map[string]net.IOCountersStat{"eth0": {BytesRecv: 200, BytesSent: 2000, Errin: 2}},
+					after:     time.Second,
+					wantRecv:  0,
+					wantSent:  1000,
+					wantErrIn: map[string]float64{"eth0": 0},
+				},
+			},
+		},
+		{
+			name: "disappearing interface is dropped from the totals",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 0}, "wg0": {BytesRecv: 0}}},
+				{
+					counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 100}},
+					after:    time.Second,
+					wantRecv: 100,
+				},
+				{
+					// Back again, it starts over like a first sample
+					counters:  map[string]net.IOCountersStat{"eth0": {BytesRecv: 200}, "wg0": {BytesRecv: 5000, Errin: 7}},
+					after:     time.Second,
+					wantRecv:  100,
+					wantErrIn: map[string]float64{"wg0": 0},
+				},
+			},
+		},
+		{
+			name: "no time elapsed reports zero",
+			passes: []pass{
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 0}}},
+				{counters: map[string]net.IOCountersStat{"eth0": {BytesRecv: 100}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newInterfaceTracker()
+			now := start
+
+			for i, p := range tt.passes {
+				now = now.Add(p.after)
+
+				ifaces := make([]NetworkInterface, 0, len(p.counters))
+				for name := range p.counters {
+					ifaces = append(ifaces, NetworkInterface{Name: name})
+				}
+
+				recv, sent := tracker.setRates(ifaces, p.counters, now)
+				if recv != p.wantRecv || sent != p.wantSent {
+					t.Errorf("pass %d: rates = %v recv, %v sent, want %v, %v", i, recv, sent, p.wantRecv, p.wantSent)
+				}
+				for _, iface := range ifaces {
+					if want, ok := p.wantErrIn[iface.Name]; ok && iface.ErrInRate != want {
+						t.Errorf("pass %d: %s errInRate = %v, want %v", i, iface.Name, iface.ErrInRate, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCollectNetwork(t *testing.T) {
+	src := &fakeSource{
+		netIO: []net.IOCountersStat{
+			{Name: "lo", BytesRecv: 10, BytesSent: 10},
+			{Name: "eth0", BytesRecv: 100, BytesSent: 50, Errin: 1},
+			{Name: "docker0", BytesRecv: 1000, BytesSent: 1000},
+			{Name: "tun0", BytesRecv: 5, BytesSent: 5}, // no interface details
+		},
+		interfaces: []net.InterfaceStat{
+			{Name: "docker0", Flags: []string{"up"}},
+			{Name: "eth0", HardwareAddr: "52:54:00:12:34:56", Flags: []string{"up", "broadcast"}, Addrs: []net.InterfaceAddr{{Addr: "192.0.2.2/24"}}},
+			{Name: "lo", Flags: []string{"up", "loopback"}},
+		},
+	}
+
+	vitals := newSystemVitals()
+	collectNetwork(context.Background(), src, vitals, newNameFilter("", "docker*"), newInterfaceTracker())
+
+	if got := vitals.Network.BytesRecv; got != 115 {
+		t.Errorf("total bytes received = %d, want 115 without docker0", got)
+	}
+	if got := len(vitals.NetworkIfaces); got != 2 {
+		t.Fatalf("got %d interfaces, want lo and eth0", got)
+	}
+
+	eth0 := vitals.NetworkIfaces[1]
+	if eth0.Name != "eth0" || eth0.MacAddr != "52:54:00:12:34:56" || eth0.IPv4 != "192.0.2.2" || !eth0.IsUp || eth0.ErrIn != 1 {
+		t.Errorf("eth0 joined with the wrong interface details: %+v", eth0)
+	}
+}
+
+func TestCollectNetworkFailure(t *testing.T) {
+	vitals := newSystemVitals()
+	collectNetwork(context.Background(), &fakeSource{err: errors.New("no /proc")}, vitals, nameFilter{}, newInterfaceTracker())
+
+	if _, ok := vitals.CollectionErrors["Network"]; !ok {
+		t.Error("failed read not recorded in CollectionErrors")
+	}
+}
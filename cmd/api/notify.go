@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	alertStateFiring   = "firing"
+	alertStateResolved = "resolved"
+)
+
+// alertEvent is emitted when a metric starts or stops breaching its threshold
+type alertEvent struct {
+	Hostname  string    `json:"hostname"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Severity  string    `json:"severity,omitempty"`
+	State     string    `json:"state"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// alertSink delivers alert events to an external destination
+type alertSink interface {
+	send(event alertEvent) error
+}
+
+// alertNotifier debounces threshold readings into firing/resolved transitions
+// and forwards them to its sinks. A transition is only reported once the new
+// state has been observed for `consecutive` samples in a row.
+type alertNotifier struct {
+	consecutive int
+	sinks       []alertSink
+	states      map[string]*alertState
+}
+
+type alertState struct {
+	firing bool
+	streak int
+}
+
+func newAlertNotifier(consecutive int, sinks ...alertSink) *alertNotifier {
+	if consecutive < 1 {
+		consecutive = 1
+	}
+
+	return &alertNotifier{
+		consecutive: consecutive,
+		sinks:       sinks,
+		states:      make(map[string]*alertState),
+	}
+}
+
+// observe feeds one tick of threshold readings through the state machine.
+// It is called from the collector goroutine only.
+func (n *alertNotifier) observe(vitals *SystemVitals, readings []Alert) {
+	hostname := ""
+	if vitals.HostInfo != nil {
+		hostname = vitals.HostInfo.Hostname
+	} else {
+		hostname, _ = os.Hostname()
+	}
+
+	for _, reading := range readings {
+		state, ok := n.states[reading.Metric]
+		if !ok {
+			state = &alertState{}
+			n.states[reading.Metric] = state
+		}
+
+		breaching := reading.Severity != ""
+		if breaching == state.firing {
+			state.streak = 0
+			continue
+		}
+
+		state.streak++
+		if state.streak < n.consecutive {
+			continue
+		}
+
+		state.firing = breaching
+		state.streak = 0
+
+		event := alertEvent{
+			Hostname:  hostname,
+			Metric:    reading.Metric,
+			Value:     reading.Value,
+			Threshold: reading.Threshold,
+			Severity:  reading.Severity,
+			State:     alertStateResolved,
+			Timestamp: vitals.LastUpdated,
+		}
+		if breaching {
+			event.State = alertStateFiring
+		}
+
+		n.dispatch(event)
+	}
+}
+
+// dispatch sends the event to every sink without blocking the collector
+func (n *alertNotifier) dispatch(event alertEvent) {
+	for _, sink := range n.sinks {
+		go func(sink alertSink) {
+			if err := sink.send(event); err != nil {
+				slog.Warn("alert notification failed", "metric", event.Metric, "state", event.State, "error", err)
+			}
+		}(sink)
+	}
+}
+
+// Webhook payload formats (ALERT_WEBHOOK_FORMAT)
+const (
+	webhookFormatRaw     = "raw"
+	webhookFormatSlack   = "slack"
+	webhookFormatDiscord = "discord"
+)
+
+// parseWebhookFormat validates an ALERT_WEBHOOK_FORMAT value
+func parseWebhookFormat(raw string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(raw)); format {
+	case webhookFormatRaw, webhookFormatSlack, webhookFormatDiscord:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown webhook format %q, want raw, slack or discord", raw)
+	}
+}
+
+// webhookSink POSTs alert events as JSON to a URL, either as the raw event or
+// shaped as a Slack or Discord incoming webhook message
+type webhookSink struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+func newWebhookSink(url, format string) *webhookSink {
+	return &webhookSink{
+		url:    url,
+		format: format,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Message colors by severity, green once resolved
+const (
+	alertColorWarning  = 0xf2c744
+	alertColorCritical = 0xd9352b
+	alertColorResolved = 0x2eb67d
+)
+
+func alertColor(event alertEvent) int {
+	switch {
+	case event.State == alertStateResolved:
+		return alertColorResolved
+	case event.Severity == severityCritical:
+		return alertColorCritical
+	default:
+		return alertColorWarning
+	}
+}
+
+// alertSummary is a one-line, human-readable description of an event
+func alertSummary(event alertEvent) string {
+	if event.State == alertStateFiring {
+		return fmt.Sprintf("%s %s on %s: %.2f (threshold %.2f)",
+			strings.ToUpper(event.Severity), event.Metric, event.Hostname, event.Value, event.Threshold)
+	}
+
+	return fmt.Sprintf("RESOLVED %s on %s: %.2f (threshold %.2f)",
+		event.Metric, event.Hostname, event.Value, event.Threshold)
+}
+
+// payload renders the event in the sink's format
+func (s *webhookSink) payload(event alertEvent) any {
+	summary := alertSummary(event)
+	color := alertColor(event)
+
+	switch s.format {
+	case webhookFormatSlack:
+		return map[string]any{
+			"text": summary,
+			"attachments": []map[string]any{{
+				"color":    fmt.Sprintf("#%06x", color),
+				"fallback": summary,
+				"fields": []map[string]any{
+					{"title": "Metric", "value": event.Metric, "short": true},
+					{"title": "Host", "value": event.Hostname, "short": true},
+					{"title": "Value", "value": fmt.Sprintf("%.2f", event.Value), "short": true},
+					{"title": "Threshold", "value": fmt.Sprintf("%.2f", event.Threshold), "short": true},
+				},
+				"ts": event.Timestamp.Unix(),
+			}},
+		}
+	case webhookFormatDiscord:
+		return map[string]any{
+			"content": summary,
+			"embeds": []map[string]any{{
+				"title": fmt.Sprintf("%s %s", event.Metric, event.State),
+				"color": color,
+				"fields": []map[string]any{
+					{"name": "Host", "value": event.Hostname, "inline": true},
+					{"name": "Value", "value": fmt.Sprintf("%.2f", event.Value), "inline": true},
+					{"name": "Threshold", "value": fmt.Sprintf("%.2f", event.Threshold), "inline": true},
+				},
+				"timestamp": event.Timestamp.Format(time.RFC3339),
+			}},
+		}
+	default:
+		return event
+	}
+}
+
+func (s *webhookSink) send(event alertEvent) error {
+	body, err := json.Marshal(s.payload(event))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
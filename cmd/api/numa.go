@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NumaInfo contains the memory of one NUMA node and the CPUs local to it
+type NumaInfo struct {
+	Node        int     `json:"node"`
+	CPUs        string  `json:"cpus"` // kernel cpulist format, e.g. "0-15,32-47"
+	MemTotal    uint64  `json:"memTotal"`
+	MemFree     uint64  `json:"memFree"`
+	MemUsed     uint64  `json:"memUsed"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+const numaNodePath = "/sys/devices/system/node"
+
+var numaNodeDir = regexp.MustCompile(`^node(\d+)$`)
+
+// numaNode is the static part of a node, read once
+type numaNode struct {
+	id   int
+	dir  string
+	cpus string
+}
+
+var (
+	numaOnce  sync.Once
+	numaNodes []numaNode
+)
+
+// lookupNumaNodes reads the node topology, which doesn't change while running
+func lookupNumaNodes() []numaNode {
+	numaOnce.Do(func() {
+		dirs, _ := filepath.Glob(filepath.Join(numaNodePath, "node*"))
+		for _, dir := range dirs {
+			m := numaNodeDir.FindStringSubmatch(filepath.Base(dir))
+			if m == nil {
+				continue
+			}
+			id, _ := strconv.Atoi(m[1])
+			numaNodes = append(numaNodes, numaNode{
+				id:   id,
+				dir:  dir,
+				cpus: readSysString(filepath.Join(dir, "cpulist")),
+			})
+		}
+		sort.Slice(numaNodes, func(i, j int) bool { return numaNodes[i].id < numaNodes[j].id })
+	})
+
+	return numaNodes
+}
+
+// collectNumaNodes reports per-node memory from each node's meminfo. Single
+// node machines, and other platforms, get an empty list.
+func collectNumaNodes(vitals *SystemVitals) {
+	vitals.NumaNodes = make([]NumaInfo, 0)
+
+	nodes := lookupNumaNodes()
+	if len(nodes) < 2 {
+		return
+	}
+
+	for _, node := range nodes {
+		data, err := os.ReadFile(filepath.Join(node.dir, "meminfo"))
+		if err != nil {
+			vitals.collectFailed("NUMA", err)
+			continue
+		}
+
+		info := NumaInfo{Node: node.id, CPUs: node.cpus}
+		values := parseNodeMeminfo(data)
+		info.MemTotal = values["MemTotal"]
+		info.MemFree = values["MemFree"]
+		info.MemUsed = info.MemTotal - min(info.MemFree, info.MemTotal)
+		if info.MemTotal > 0 {
+			info.UsedPercent = float64(info.MemUsed) / float64(info.MemTotal) * 100
+		}
+
+		vitals.NumaNodes = append(vitals.NumaNodes, info)
+	}
+}
+
+// parseNodeMeminfo parses lines like "Node 0 MemTotal:  32768000 kB" into bytes
+func parseNodeMeminfo(data []byte) map[string]uint64 {
+	values := make(map[string]uint64)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "Node" {
+			continue
+		}
+
+		n, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 4 && fields[4] == "kB" {
+			n *= 1024
+		}
+		values[strings.TrimSuffix(fields[2], ":")] = n
+	}
+
+	return values
+}
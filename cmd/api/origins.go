@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originList holds the browser origins allowed to call the API, parsed from
+// FRONTEND_URL. Entries containing * are glob patterns, e.g.
+// https://*.example.com for every subdomain; a lone * allows any origin.
+type originList struct {
+	exact    []string
+	patterns []string
+}
+
+// parseOrigins parses a comma separated list of origins such as
+// "http://192.168.1.10:3000,https://dash.example.com". Each entry must be a
+// scheme and host with no path, as browsers send in the Origin header.
+func parseOrigins(raw string) (originList, error) {
+	var list originList
+
+	for _, entry := range strings.Split(raw, ",") {
+		origin := strings.ToLower(strings.TrimRight(strings.TrimSpace(entry), "/"))
+		if origin == "" {
+			continue
+		}
+
+		if origin == "*" {
+			list.exact = append(list.exact, origin)
+			continue
+		}
+
+		// Patterns are checked with a placeholder in place of the wildcard
+		u, err := url.Parse(strings.ReplaceAll(origin, "*", "x"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return list, fmt.Errorf("%q is not an origin like https://example.com", entry)
+		}
+
+		if strings.Contains(origin, "*") {
+			list.patterns = append(list.patterns, origin)
+		} else {
+			list.exact = append(list.exact, origin)
+		}
+	}
+
+	if len(list.exact) == 0 && len(list.patterns) == 0 {
+		return list, fmt.Errorf("no origins given")
+	}
+
+	return list, nil
+}
+
+// allows reports whether origin may make cross-origin requests. Origins are
+// compared case-insensitively, as the CORS middleware does.
+func (l originList) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range l.exact {
+		if origin == allowed || allowed == "*" {
+			return true
+		}
+	}
+
+	return matchesAny(origin, l.patterns)
+}
+
+// corsOrigins returns the options for the CORS middleware. Exact origins are
+// handed over as they are; patterns need a matching function.
+func (l originList) corsOrigins() ([]string, func(r *http.Request, origin string) bool) {
+	if len(l.patterns) == 0 {
+		return l.exact, nil
+	}
+
+	return nil, func(_ *http.Request, origin string) bool {
+		return l.allows(origin)
+	}
+}
+
+// String lists every allowed origin and pattern
+func (l originList) String() string {
+	return strings.Join(append(append([]string{}, l.exact...), l.patterns...), ",")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otelConfig enables OpenTelemetry export. The exporters also read the other
+// standard OTEL_EXPORTER_OTLP_* and OTEL_* variables, such as headers and the
+// metric export interval.
+type otelConfig struct {
+	endpoint string // OTLP/HTTP base URL, e.g. http://collector:4318
+}
+
+func (c otelConfig) enabled() bool {
+	return c.endpoint != ""
+}
+
+// telemetry owns the OpenTelemetry providers so they can be flushed on exit
+type telemetry struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// newTelemetry sets up OTLP metric and trace export. Metrics are observed from
+// the collector's latest snapshot, so exporting adds no collection work.
+func newTelemetry(ctx context.Context, c *collector, version string) (*telemetry, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "homeserver-vitals"),
+			attribute.String("service.version", version),
+		),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &telemetry{
+		meterProvider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		),
+		tracerProvider: sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithBatcher(traceExporter),
+		),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+
+	if err := registerVitalsMetrics(t.meterProvider.Meter("homeserver-vitals"), c); err != nil {
+		return nil, errors.Join(err, t.shutdown(ctx))
+	}
+
+	return t, nil
+}
+
+// middleware records a span for every request, continuing any trace the
+// client propagated
+func (t *telemetry) middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithTracerProvider(t.tracerProvider),
+		otelhttp.WithMeterProvider(t.meterProvider),
+		otelhttp.WithPropagators(t.propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
+
+// shutdown flushes pending metrics and spans
+func (t *telemetry) shutdown(ctx context.Context) error {
+	return errors.Join(t.meterProvider.Shutdown(ctx), t.tracerProvider.Shutdown(ctx))
+}
+
+// registerVitalsMetrics creates the vitals instruments, named after the
+// OpenTelemetry system metric conventions where one exists
+func registerVitalsMetrics(meter metric.Meter, c *collector) error {
+	var errs []error
+	gauge := func(name, unit, description string) metric.Float64ObservableGauge {
+		g, err := meter.Float64ObservableGauge(name, metric.WithUnit(unit), metric.WithDescription(description))
+		errs = append(errs, err)
+		return g
+	}
+
+	cpuUtilization := gauge("system.cpu.utilization", "1", "Total CPU usage across cores")
+	memoryUtilization := gauge("system.memory.utilization", "1", "Memory in use")
+	memoryUsage := gauge("system.memory.usage", "By", "Memory in use")
+	swapUtilization := gauge("system.paging.utilization", "1", "Swap in use")
+	loadAverage := gauge("system.cpu.load_average", "1", "Load average by period")
+	filesystemUtilization := gauge("system.filesystem.utilization", "1", "Used share of each mounted filesystem")
+	temperature := gauge("system.temperature", "Cel", "Temperature sensor readings")
+	uptime := gauge("system.uptime", "s", "Time since boot")
+
+	processes, err := meter.Int64ObservableGauge("system.process.count", metric.WithDescription("Processes by state"))
+	errs = append(errs, err)
+
+	networkIO, err := meter.Int64ObservableCounter("system.network.io", metric.WithUnit("By"), metric.WithDescription("Bytes transferred across the included interfaces"))
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		vitals := c.snapshot()
+		if vitals == nil {
+			return nil
+		}
+
+		o.ObserveFloat64(cpuUtilization, vitals.CPUUsage/100)
+		if vitals.Memory != nil {
+			o.ObserveFloat64(memoryUtilization, vitals.Memory.UsedPercent/100)
+			o.ObserveFloat64(memoryUsage, float64(vitals.Memory.Used))
+		}
+		if vitals.Swap != nil && vitals.Swap.Total > 0 {
+			o.ObserveFloat64(swapUtilization, vitals.Swap.UsedPercent/100)
+		}
+		if l := vitals.LoadAvg; l != nil {
+			o.ObserveFloat64(loadAverage, l.Load1, metric.WithAttributes(attribute.String("period", "1m")))
+			o.ObserveFloat64(loadAverage, l.Load5, metric.WithAttributes(attribute.String("period", "5m")))
+			o.ObserveFloat64(loadAverage, l.Load15, metric.WithAttributes(attribute.String("period", "15m")))
+		}
+		for _, d := range vitals.Disks {
+			o.ObserveFloat64(filesystemUtilization, d.UsedPercent/100, metric.WithAttributes(
+				attribute.String("system.filesystem.mountpoint", d.MountPoint),
+				attribute.String("system.filesystem.type", d.FileSystem),
+			))
+		}
+		for _, t := range vitals.Temperature {
+			o.ObserveFloat64(temperature, t.Temperature, metric.WithAttributes(attribute.String("sensor", t.SensorKey)))
+		}
+		o.ObserveFloat64(uptime, float64(vitals.Uptime))
+
+		if len(vitals.ProcessStates) == 0 {
+			o.ObserveInt64(processes, int64(vitals.Processes))
+		}
+		for state, count := range vitals.ProcessStates {
+			o.ObserveInt64(processes, int64(count), metric.WithAttributes(attribute.String("process.status", state)))
+		}
+
+		o.ObserveInt64(networkIO, int64(vitals.Network.BytesRecv), metric.WithAttributes(attribute.String("network.io.direction", "receive")))
+		o.ObserveInt64(networkIO, int64(vitals.Network.BytesSent), metric.WithAttributes(attribute.String("network.io.direction", "transmit")))
+
+		return nil
+	}, cpuUtilization, memoryUtilization, memoryUsage, swapUtilization, loadAverage,
+		filesystemUtilization, temperature, uptime, processes, networkIO)
+
+	return err
+}
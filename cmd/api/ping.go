@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Probe methods reported in PingResult
+const (
+	pingMethodICMP = "icmp"
+	pingMethodTCP  = "tcp"
+)
+
+// PingResult is the round-trip latency to one PING_TARGETS entry
+type PingResult struct {
+	Target string  `json:"target"`
+	Method string  `json:"method"` // icmp, or tcp when ICMP isn't permitted or the target has a port
+	RTTms  float64 `json:"rttMs"`  // mean over the answered probes, 0 when none were
+	Loss   float64 `json:"loss"`   // percent of probes unanswered
+	Error  string  `json:"error,omitempty"`
+}
+
+type pingOptions struct {
+	targets []string // host or host:port
+	count   int      // probes per target per collection
+	timeout time.Duration
+	tcpPort string // port timed when ICMP isn't permitted
+}
+
+var (
+	// pingSeq numbers echo requests so replies can be matched to them
+	pingSeq atomic.Uint32
+
+	// icmpDenied is logged once when falling back to TCP connect times
+	icmpDenied sync.Once
+)
+
+// collectPings probes every target concurrently. Each target's probes run one
+// after another, each bounded by the probe timeout.
+func collectPings(ctx context.Context, vitals *SystemVitals, opts pingOptions) {
+	vitals.PingTargets = make([]PingResult, len(opts.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range opts.targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			vitals.PingTargets[i] = pingTarget(ctx, target, opts)
+		}(i, target)
+	}
+	wg.Wait()
+}
+
+func pingTarget(ctx context.Context, target string, opts pingOptions) PingResult {
+	result := PingResult{Target: target, Method: pingMethodICMP, Loss: 100}
+
+	host, port, err := net.SplitHostPort(target)
+	if err == nil {
+		result.Method = pingMethodTCP
+	} else {
+		host, port = target, opts.tcpPort
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	ip := addrs[0].IP
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			ip = addr.IP
+			break
+		}
+	}
+
+	var total time.Duration
+	answered := 0
+	for range opts.count {
+		var rtt time.Duration
+		if result.Method == pingMethodICMP {
+			rtt, err = pingICMP(ctx, ip, opts.timeout)
+			if errors.Is(err, errICMPDenied) {
+				icmpDenied.Do(func() {
+					slog.Info("ICMP not permitted, ping probes time TCP connects instead", "port", opts.tcpPort)
+				})
+				result.Method = pingMethodTCP
+			}
+		}
+		if result.Method == pingMethodTCP {
+			rtt, err = pingTCP(ctx, net.JoinHostPort(ip.String(), port), opts.timeout)
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		total += rtt
+		answered++
+	}
+
+	if answered > 0 {
+		result.RTTms = float64(total.Microseconds()) / float64(answered) / 1000
+		result.Error = ""
+	}
+	result.Loss = float64(opts.count-answered) / float64(opts.count) * 100
+
+	return result
+}
+
+// errICMPDenied is returned when no ICMP socket can be opened, unprivileged
+// or raw
+var errICMPDenied = errors.New("ICMP not permitted")
+
+// pingICMP sends one echo request and waits for its reply. Unprivileged ICMP
+// sockets are tried first (Linux needs net.ipv4.ping_group_range to cover the
+// process's group), then raw sockets, which need root or CAP_NET_RAW.
+func pingICMP(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	network, raw, local, proto := "udp4", "ip4:icmp", "0.0.0.0", 1
+	var echo, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		network, raw, local, proto = "udp6", "ip6:ipv6-icmp", "::", 58
+		echo, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	privileged := false
+	conn, err := icmp.ListenPacket(network, local)
+	if err != nil {
+		if conn, err = icmp.ListenPacket(raw, local); err != nil {
+			return 0, errICMPDenied
+		}
+		privileged = true
+	}
+	defer conn.Close()
+
+	// Unprivileged sockets get their ID assigned by the kernel, which also
+	// filters replies; raw sockets see every reply, so the ID is checked
+	id := os.Getpid() & 0xffff
+	seq := int(pingSeq.Add(1) & 0xffff)
+	msg, err := (&icmp.Message{
+		Type: echo,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("homeserver-vitals")},
+	}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if privileged {
+		dst = &net.IPAddr{IP: ip}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, fmt.Errorf("no reply from %s: %w", ip, err)
+		}
+
+		parsed, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || parsed.Type != reply {
+			continue
+		}
+		body, ok := parsed.Body.(*icmp.Echo)
+		if !ok || body.Seq != seq || privileged && body.ID != id {
+			continue
+		}
+
+		return time.Since(start), nil
+	}
+}
+
+// pingTCP times a TCP connect to addr. A refused connection still took a
+// round trip, so it counts as an answer.
+func pingTCP(ctx context.Context, addr string, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	rtt := time.Since(start)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return rtt, nil
+		}
+		return 0, err
+	}
+	conn.Close()
+
+	return rtt, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ListeningPort describes a socket accepting connections or datagrams
+type ListeningPort struct {
+	Protocol string `json:"protocol"` // tcp4, tcp6, udp4 or udp6
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	State    string `json:"state"`
+	PID      int32  `json:"pid"`     // 0 when the owner can't be determined
+	Process  string `json:"process"` // empty when the owner can't be determined
+}
+
+type portsResponse struct {
+	Ports       []ListeningPort `json:"ports"`
+	LastUpdated time.Time       `json:"lastUpdated"`
+}
+
+// portsHandler lists listening TCP sockets and bound UDP sockets with their
+// owning process, like `netstat -tulnp`. Owners of other users' sockets are
+// only visible when running as root.
+func (app *application) portsHandler(w http.ResponseWriter, r *http.Request) {
+	conns, err := net.ConnectionsWithContext(r.Context(), "inet")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	names := make(map[int32]string)
+	ports := make([]ListeningPort, 0)
+	for _, conn := range conns {
+		protocol := socketProtocol(conn)
+		if protocol == "" {
+			continue
+		}
+
+		// TCP sockets are listening in the LISTEN state; UDP sockets have no
+		// state, and are listening while not connected to a remote peer
+		isTCP := conn.Type == syscall.SOCK_STREAM
+		if (isTCP && conn.Status != "LISTEN") || (!isTCP && conn.Raddr.IP != "") {
+			continue
+		}
+
+		port := ListeningPort{
+			Protocol: protocol,
+			Address:  conn.Laddr.IP,
+			Port:     conn.Laddr.Port,
+			State:    conn.Status,
+			PID:      conn.Pid,
+		}
+
+		if conn.Pid > 0 {
+			name, ok := names[conn.Pid]
+			if !ok {
+				if p, err := process.NewProcessWithContext(r.Context(), conn.Pid); err == nil {
+					name, _ = p.NameWithContext(r.Context())
+				}
+				names[conn.Pid] = name
+			}
+			port.Process = name
+		}
+
+		ports = append(ports, port)
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+
+	app.writeMetric(w, &portsResponse{
+		Ports:       ports,
+		LastUpdated: time.Now(),
+	})
+}
+
+// socketProtocol names a socket's transport and address family, or returns
+// an empty string for anything other than TCP or UDP over IPv4 or IPv6
+func socketProtocol(conn net.ConnectionStat) string {
+	var protocol string
+	switch conn.Type {
+	case syscall.SOCK_STREAM:
+		protocol = "tcp"
+	case syscall.SOCK_DGRAM:
+		protocol = "udp"
+	default:
+		return ""
+	}
+
+	switch conn.Family {
+	case syscall.AF_INET:
+		return protocol + "4"
+	case syscall.AF_INET6:
+		return protocol + "6"
+	default:
+		return ""
+	}
+}
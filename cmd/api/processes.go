@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/shirou/gopsutil/process"
+)
+
+type processesResponse struct {
+	Processes     int            `json:"processes"`
+	ProcessStates map[string]int `json:"processStates"`
+	Zombies       int            `json:"zombies"`
+	TopProcesses  []TopProcess   `json:"topProcesses"`
+	SortBy        string         `json:"sortBy"`
+	LastUpdated   time.Time      `json:"lastUpdated"`
+}
+
+// processesHandler lists the top processes, ordered by ?sort=cpu|memory
+// (defaulting to PROCESS_SORT), limited to ?top= (defaulting to TOP_PROCESSES)
+// and optionally narrowed by ?process_filter=
+func (app *application) processesHandler(w http.ResponseWriter, r *http.Request) {
+	opts := app.config.collector.options.processes
+
+	if sortBy := r.URL.Query().Get("sort"); sortBy != "" {
+		if sortBy != processSortCPU && sortBy != processSortMemory {
+			app.badRequestResponse(w, r, fmt.Errorf("sort must be %q or %q", processSortCPU, processSortMemory))
+			return
+		}
+		opts.sortBy = sortBy
+	}
+
+	if top := r.URL.Query().Get("top"); top != "" {
+		limit, err := strconv.Atoi(top)
+		if err != nil || limit < 1 || limit > maxTopProcesses {
+			app.badRequestResponse(w, r, fmt.Errorf("top must be between 1 and %d", maxTopProcesses))
+			return
+		}
+		opts.limit = limit
+	}
+
+	opts.filter = strings.TrimSpace(r.URL.Query().Get("process_filter"))
+
+	vitals := &SystemVitals{LastUpdated: time.Now()}
+	collectProcesses(r.Context(), app.source, vitals, opts, newProcessTracker())
+
+	app.writeMetric(w, &processesResponse{
+		Processes:     vitals.Processes,
+		ProcessStates: vitals.ProcessStates,
+		Zombies:       vitals.Zombies,
+		TopProcesses:  vitals.TopProcesses,
+		SortBy:        opts.sortBy,
+		LastUpdated:   vitals.LastUpdated,
+	})
+}
+
+// processWarmup is how long a process tracker without history samples CPU
+// usage for before reporting it
+const processWarmup = 500 * time.Millisecond
+
+// processTracker keeps process handles between collection passes so per-process
+// CPU usage reflects the time since the previous pass rather than the
+// process's lifetime average
+type processTracker struct {
+	mu    sync.Mutex
+	procs map[int32]*process.Process
+}
+
+func newProcessTracker() *processTracker {
+	return &processTracker{
+		procs: make(map[int32]*process.Process),
+	}
+}
+
+func (t *processTracker) empty() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.procs) == 0
+}
+
+// track swaps freshly listed processes for the handles seen on the previous
+// pass, so Percent(0) measures against the last reading. New processes are
+// primed and report 0% until the next pass. Processes that have exited are
+// forgotten.
+func (t *processTracker) track(processes []*process.Process) []*process.Process {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[int32]*process.Process, len(processes))
+	tracked := make([]*process.Process, 0, len(processes))
+
+	for _, p := range processes {
+		if known, ok := t.procs[p.Pid]; ok && sameProcess(known, p) {
+			p = known
+		} else {
+			// Prime the CPU counters
+			p.Percent(0)
+		}
+
+		seen[p.Pid] = p
+		tracked = append(tracked, p)
+	}
+
+	t.procs = seen
+
+	return tracked
+}
+
+// sameProcess guards against PID reuse by comparing creation times
+func sameProcess(a, b *process.Process) bool {
+	aCreated, errA := a.CreateTime()
+	bCreated, errB := b.CreateTime()
+
+	return errA == nil && errB == nil && aCreated == bCreated
+}
+
+type processActionResponse struct {
+	PID    int32  `json:"pid"`
+	Signal string `json:"signal"`
+	Status string `json:"status"`
+}
+
+// killProcessHandler sends SIGTERM to the process, or SIGKILL with ?force=true
+func (app *application) killProcessHandler(w http.ResponseWriter, r *http.Request) {
+	sig := syscall.SIGTERM
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); force {
+		sig = syscall.SIGKILL
+	}
+
+	app.signalProcess(w, r, sig)
+}
+
+// signalRequest is the body of POST /processes/{pid}/signal
+type signalRequest struct {
+	Signal string `json:"signal"` // e.g. "HUP" or "SIGHUP"
+}
+
+// signalProcessHandler sends the signal named in the request body
+func (app *application) signalProcessHandler(w http.ResponseWriter, r *http.Request) {
+	var req signalRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(req.Signal)), "SIG")
+	sig, ok := processSignals[name]
+	if !ok {
+		app.badRequestResponse(w, r, fmt.Errorf("unknown signal %q", req.Signal))
+		return
+	}
+
+	app.signalProcess(w, r, sig)
+}
+
+// signalProcess looks up the process named by the {pid} URL parameter and
+// delivers sig to it, mapping lookup and permission failures to HTTP errors
+func (app *application) signalProcess(w http.ResponseWriter, r *http.Request, sig syscall.Signal) {
+	pid, err := strconv.ParseInt(chi.URLParam(r, "pid"), 10, 32)
+	if err != nil || pid <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid pid %q", chi.URLParam(r, "pid")))
+		return
+	}
+
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		if errors.Is(err, process.ErrorProcessNotRunning) {
+			app.notFoundResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := proc.SendSignal(sig); err != nil {
+		switch {
+		case errors.Is(err, os.ErrPermission):
+			app.forbiddenResponse(w, r, err)
+		case errors.Is(err, os.ErrProcessDone), errors.Is(err, syscall.ESRCH):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	slog.Info("sent signal to process", "pid", pid, "signal", sig.String())
+
+	if err := writeJSON(w, http.StatusOK, &processActionResponse{
+		PID:    proc.Pid,
+		Signal: sig.String(),
+		Status: "sent",
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// sharedMemory returns the resident bytes the process shares with others,
+// such as libraries and shared mappings, or 0 if they can't be read
+func sharedMemory(ctx context.Context, p *process.Process) uint64 {
+	info, err := p.MemoryInfoExWithContext(ctx)
+	if err != nil {
+		return 0
+	}
+
+	return info.Shared
+}
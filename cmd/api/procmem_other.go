@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// sharedMemory is only reported on Linux
+func sharedMemory(ctx context.Context, p *process.Process) uint64 {
+	return 0
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodingProtobuf selects the binary SSE payload described by
+// proto/vitals.proto
+const encodingProtobuf = "protobuf"
+
+// marshalVitalsProto encodes the requested field groups of vitals as a
+// SystemVitals message. The ID, timestamp and collection errors are always
+// included, and nil fields means every group.
+func marshalVitalsProto(vitals *SystemVitals, fields []string) []byte {
+	b := protoUint(nil, 1, vitals.ID)
+	b = protoInt(b, 2, vitals.LastUpdated.UnixMilli())
+	for metric, msg := range vitals.CollectionErrors {
+		b = protoMapEntry(b, 3, metric, protoString(nil, 2, msg))
+	}
+
+	if fields == nil {
+		for _, appendGroup := range vitalsProtoFields {
+			b = appendGroup(b, vitals)
+		}
+		return b
+	}
+
+	for _, name := range fields {
+		b = vitalsProtoFields[name](b, vitals)
+	}
+
+	return b
+}
+
+// marshalHistoryProto encodes a history sample as a HistorySample message
+func marshalHistoryProto(sample HistorySample) []byte {
+	b := protoUint(nil, 1, sample.ID)
+	b = protoInt(b, 2, sample.Timestamp.UnixMilli())
+	b = protoDouble(b, 3, sample.CPUUsage)
+	b = protoDouble(b, 4, sample.MemoryUsedPercent)
+	b = protoDouble(b, 5, sample.Load1)
+	b = protoDouble(b, 6, sample.NetRecvRate)
+	return protoDouble(b, 7, sample.NetSendRate)
+}
+
+// vitalsProtoFields appends the message fields of each field group, keyed like
+// vitalsFields. Field numbers follow proto/vitals.proto.
+var vitalsProtoFields = map[string]func(b []byte, v *SystemVitals) []byte{
+	"cpu": func(b []byte, v *SystemVitals) []byte {
+		b = protoDouble(b, 10, v.CPUUsage)
+		b = protoPackedDoubles(b, 11, v.CPUPerCore)
+		for _, t := range v.CPUTimes {
+			b = protoMessage(b, 12, protoCPUTimes(t))
+		}
+		b = protoDouble(b, 13, v.CPUFreqMHz)
+		return protoMessage(b, 14, protoCPUTimes(v.CPUBreakdown))
+	},
+	"memory": func(b []byte, v *SystemVitals) []byte {
+		if m := v.Memory; m != nil {
+			msg := protoUint(nil, 1, m.Total)
+			msg = protoUint(msg, 2, m.Available)
+			msg = protoUint(msg, 3, m.Used)
+			msg = protoDouble(msg, 4, m.UsedPercent)
+			msg = protoUint(msg, 5, m.Free)
+			msg = protoUint(msg, 6, m.Buffers)
+			msg = protoUint(msg, 7, m.Cached)
+			msg = protoUint(msg, 8, m.Shared)
+			b = protoMessage(b, 20, msg)
+		}
+		b = protoDouble(b, 21, v.MemoryActualUsedPercent)
+		if s := v.Swap; s != nil {
+			msg := protoUint(nil, 1, s.Total)
+			msg = protoUint(msg, 2, s.Used)
+			msg = protoUint(msg, 3, s.Free)
+			msg = protoDouble(msg, 4, s.UsedPercent)
+			msg = protoUint(msg, 5, s.Sin)
+			msg = protoUint(msg, 6, s.Sout)
+			b = protoMessage(b, 22, msg)
+		}
+		b = protoDouble(b, 23, v.SwapInRate)
+		b = protoDouble(b, 24, v.SwapOutRate)
+		for _, n := range v.NumaNodes {
+			msg := protoInt(nil, 1, int64(n.Node))
+			msg = protoString(msg, 2, n.CPUs)
+			msg = protoUint(msg, 3, n.MemTotal)
+			msg = protoUint(msg, 4, n.MemFree)
+			msg = protoUint(msg, 5, n.MemUsed)
+			msg = protoDouble(msg, 6, n.UsedPercent)
+			b = protoMessage(b, 25, msg)
+		}
+		b = protoUint(b, 26, v.MemoryCommitted)
+		b = protoUint(b, 27, v.MemoryCommitLimit)
+		b = protoDouble(b, 28, v.CommitRatio)
+		return b
+	},
+	"disk": func(b []byte, v *SystemVitals) []byte {
+		for _, d := range v.Disks {
+			m := protoString(nil, 1, d.MountPoint)
+			m = protoString(m, 2, d.FileSystem)
+			m = protoUint(m, 3, d.Total)
+			m = protoUint(m, 4, d.Used)
+			m = protoUint(m, 5, d.Free)
+			m = protoDouble(m, 6, d.UsedPercent)
+			b = protoMessage(b, 30, m)
+		}
+		for name, io := range v.DiskIO {
+			m := protoUint(nil, 1, io.ReadCount)
+			m = protoUint(m, 2, io.WriteCount)
+			m = protoUint(m, 3, io.ReadBytes)
+			m = protoUint(m, 4, io.WriteBytes)
+			m = protoUint(m, 5, io.ReadTime)
+			m = protoUint(m, 6, io.WriteTime)
+			m = protoUint(m, 7, io.IoTime)
+			m = protoUint(m, 8, io.IopsInProgress)
+			for _, mount := range io.Mountpoints {
+				m = protoString(m, 9, mount)
+			}
+			b = protoMapEntry(b, 31, name, protoMessage(nil, 2, m))
+		}
+		for _, s := range v.SmartHealth {
+			m := protoString(nil, 1, s.Device)
+			m = protoString(m, 2, s.Model)
+			m = protoString(m, 3, s.Health)
+			m = protoUint(m, 4, s.ReallocatedSectors)
+			m = protoDouble(m, 5, s.Temperature)
+			m = protoString(m, 6, s.Error)
+			b = protoMessage(b, 32, m)
+		}
+		for _, p := range v.ZFSPools {
+			m := protoString(nil, 1, p.Name)
+			m = protoString(m, 2, p.State)
+			m = protoUint(m, 3, p.Size)
+			m = protoUint(m, 4, p.Allocated)
+			m = protoUint(m, 5, p.Free)
+			m = protoDouble(m, 6, p.UsedPercent)
+			m = protoString(m, 7, p.LastScrub)
+			b = protoMessage(b, 33, m)
+		}
+		for _, r := range v.RaidArrays {
+			m := protoString(nil, 1, r.Device)
+			m = protoString(m, 2, r.Level)
+			m = protoString(m, 3, r.State)
+			m = protoInt(m, 4, int64(r.ActiveDisks))
+			m = protoInt(m, 5, int64(r.TotalDisks))
+			m = protoInt(m, 6, int64(r.FailedDisks))
+			b = protoMessage(b, 34, m)
+		}
+		return b
+	},
+	"network": func(b []byte, v *SystemVitals) []byte {
+		n := v.Network
+		b = protoMessage(b, 40, protoNetworkCounters(n.BytesSent, n.BytesRecv, n.PacketsSent, n.PacketsRecv, n.Errin, n.Errout, n.Dropin, n.Dropout))
+		for _, iface := range v.NetworkIfaces {
+			m := protoString(nil, 1, iface.Name)
+			m = protoString(m, 2, iface.IPAddress)
+			m = protoString(m, 3, iface.IPv4)
+			m = protoString(m, 4, iface.IPv6)
+			for _, addr := range iface.Addresses {
+				m = protoString(m, 5, addr)
+			}
+			m = protoString(m, 6, iface.MacAddr)
+			m = protoBool(m, 7, iface.IsUp)
+			m = protoMessage(m, 8, protoNetworkCounters(iface.BytesSent, iface.BytesRecv, iface.PacketsSent, iface.PacketsRecv, iface.ErrIn, iface.ErrOut, iface.DropIn, iface.DropOut))
+			m = protoDouble(m, 9, iface.ErrInRate)
+			m = protoDouble(m, 10, iface.ErrOutRate)
+			m = protoDouble(m, 11, iface.DropInRate)
+			m = protoDouble(m, 12, iface.DropOutRate)
+			m = protoInt(m, 13, iface.SpeedMbps)
+			b = protoMessage(b, 41, m)
+		}
+		m := protoInt(nil, 1, int64(v.Connections.Total))
+		for state, count := range v.Connections.States {
+			m = protoMapEntry(m, 2, state, protoInt(nil, 2, int64(count)))
+		}
+		b = protoMessage(b, 42, m)
+		if c := v.Connectivity; c != nil {
+			m := protoString(nil, 1, c.PublicIP)
+			m = protoString(m, 2, c.Gateway)
+			m = protoBool(m, 3, c.GatewayReachable)
+			m = protoBool(m, 4, c.InternetReachable)
+			b = protoMessage(b, 43, m)
+		}
+		for _, p := range v.PingTargets {
+			m := protoString(nil, 1, p.Target)
+			m = protoString(m, 2, p.Method)
+			m = protoDouble(m, 3, p.RTTms)
+			m = protoDouble(m, 4, p.Loss)
+			m = protoString(m, 5, p.Error)
+			b = protoMessage(b, 44, m)
+		}
+		return b
+	},
+	"host": func(b []byte, v *SystemVitals) []byte {
+		if h := v.HostInfo; h != nil {
+			m := protoString(nil, 1, h.Hostname)
+			m = protoUint(m, 2, h.Procs)
+			m = protoString(m, 3, h.OS)
+			m = protoString(m, 4, h.Platform)
+			m = protoString(m, 5, h.PlatformFamily)
+			m = protoString(m, 6, h.PlatformVersion)
+			m = protoString(m, 7, h.KernelVersion)
+			m = protoString(m, 8, h.KernelArch)
+			m = protoString(m, 9, h.HostID)
+			b = protoMessage(b, 50, m)
+		}
+		b = protoUint(b, 51, v.Uptime)
+		b = protoUint(b, 52, v.AppUptime)
+		for _, u := range v.Users {
+			m := protoString(nil, 1, u.User)
+			m = protoString(m, 2, u.Terminal)
+			m = protoString(m, 3, u.Host)
+			m = protoInt(m, 4, u.LoginTime)
+			b = protoMessage(b, 53, m)
+		}
+		b = protoUint(b, 54, v.BootTime)
+		b = protoString(b, 55, v.VirtualizationSystem)
+		return protoString(b, 56, v.VirtualizationRole)
+	},
+	"hardware": func(b []byte, v *SystemVitals) []byte {
+		h := v.Hardware
+		m := protoString(nil, 1, h.CPUModel)
+		m = protoInt(m, 2, int64(h.CPUCores))
+		m = protoInt(m, 3, int64(h.CPUThreads))
+		m = protoDouble(m, 4, h.CPUFreqMaxMHz)
+		m = protoUint(m, 5, h.TotalMemory)
+		m = protoString(m, 6, h.SystemVendor)
+		m = protoString(m, 7, h.SystemModel)
+		return protoMessage(b, 60, m)
+	},
+	"load": func(b []byte, v *SystemVitals) []byte {
+		if l := v.LoadAvg; l != nil {
+			m := protoDouble(nil, 1, l.Load1)
+			m = protoDouble(m, 2, l.Load5)
+			m = protoDouble(m, 3, l.Load15)
+			b = protoMessage(b, 70, m)
+		}
+		return protoDouble(b, 71, v.LoadPerCore)
+	},
+	"processes": func(b []byte, v *SystemVitals) []byte {
+		b = protoInt(b, 80, int64(v.Processes))
+		b = protoUint(b, 81, v.OpenFileDescriptors)
+		b = protoUint(b, 82, v.MaxFileDescriptors)
+		for state, count := range v.ProcessStates {
+			b = protoMapEntry(b, 84, state, protoInt(nil, 2, int64(count)))
+		}
+		b = protoInt(b, 85, int64(v.Zombies))
+		for _, p := range v.TopProcesses {
+			m := protoInt(nil, 1, int64(p.PID))
+			m = protoString(m, 2, p.Name)
+			m = protoDouble(m, 3, p.CPU)
+			m = protoDouble(m, 4, p.Memory)
+			m = protoString(m, 5, p.Command)
+			m = protoString(m, 6, p.User)
+			m = protoInt(m, 7, int64(p.OpenFiles))
+			m = protoInt(m, 8, p.StartTime)
+			m = protoUint(m, 9, p.RunningFor)
+			m = protoUint(m, 10, p.RSS)
+			m = protoUint(m, 11, p.VMS)
+			m = protoUint(m, 12, p.Shared)
+			m = protoString(m, 13, p.Exe)
+			b = protoMessage(b, 83, m)
+		}
+		return b
+	},
+	"temperature": func(b []byte, v *SystemVitals) []byte {
+		for _, t := range v.Temperature {
+			m := protoString(nil, 1, t.SensorKey)
+			m = protoDouble(m, 2, t.Temperature)
+			b = protoMessage(b, 90, m)
+		}
+		b = protoString(b, 91, v.TempUnit)
+		for _, p := range v.CPUPackageTemps {
+			m := protoInt(nil, 1, int64(p.Package))
+			m = protoDouble(m, 2, p.PackageTemp)
+			for _, c := range p.Cores {
+				m = protoMessage(m, 3, protoDouble(protoInt(nil, 1, int64(c.Core)), 2, c.Temperature))
+			}
+			b = protoMessage(b, 92, m)
+		}
+		return b
+	},
+	"runtime": func(b []byte, v *SystemVitals) []byte {
+		b = protoInt(b, 100, int64(v.GoRoutines))
+		return protoUint(b, 101, v.GoMemAlloc)
+	},
+	"updates": func(b []byte, v *SystemVitals) []byte {
+		return protoInt(b, 110, int64(v.SystemUpdates))
+	},
+	"gpu": func(b []byte, v *SystemVitals) []byte {
+		for _, g := range v.GPU {
+			m := protoInt(nil, 1, int64(g.Index))
+			m = protoString(m, 2, g.Name)
+			m = protoDouble(m, 3, g.Utilization)
+			m = protoUint(m, 4, g.MemoryUsed)
+			m = protoUint(m, 5, g.MemoryTotal)
+			m = protoDouble(m, 6, g.Temperature)
+			m = protoString(m, 7, g.Vendor)
+			b = protoMessage(b, 120, m)
+		}
+		return protoString(b, 91, v.TempUnit)
+	},
+	"containers": func(b []byte, v *SystemVitals) []byte {
+		for _, c := range v.Containers {
+			m := protoString(nil, 1, c.ID)
+			m = protoString(m, 2, c.Name)
+			m = protoString(m, 3, c.Image)
+			m = protoString(m, 4, c.State)
+			m = protoDouble(m, 5, c.CPUPercent)
+			m = protoUint(m, 6, c.MemoryUsage)
+			m = protoUint(m, 7, c.MemoryLimit)
+			b = protoMessage(b, 130, m)
+		}
+		return b
+	},
+	"services": func(b []byte, v *SystemVitals) []byte {
+		for _, s := range v.Services {
+			m := protoString(nil, 1, s.Name)
+			m = protoString(m, 2, s.State)
+			m = protoString(m, 3, s.SubState)
+			m = protoInt(m, 4, int64(s.PID))
+			m = protoString(m, 5, s.Error)
+			b = protoMessage(b, 140, m)
+		}
+		return protoDouble(b, 141, v.JournalErrorRate)
+	},
+	"battery": func(b []byte, v *SystemVitals) []byte {
+		for _, bat := range v.Battery {
+			m := protoString(nil, 1, bat.Name)
+			m = protoDouble(m, 2, bat.Percent)
+			m = protoString(m, 3, bat.State)
+			m = protoUint(m, 4, bat.TimeRemaining)
+			b = protoMessage(b, 150, m)
+		}
+		return b
+	},
+	"alerts": func(b []byte, v *SystemVitals) []byte {
+		for _, a := range v.Alerts {
+			m := protoString(nil, 1, a.Metric)
+			m = protoDouble(m, 2, a.Value)
+			m = protoDouble(m, 3, a.Threshold)
+			m = protoString(m, 4, a.Severity)
+			b = protoMessage(b, 160, m)
+		}
+		return b
+	},
+}
+
+// protoCPUTimes encodes a CPUTimes message
+func protoCPUTimes(t CPUTimesInfo) []byte {
+	m := protoString(nil, 1, t.CPU)
+	m = protoDouble(m, 2, t.User)
+	m = protoDouble(m, 3, t.System)
+	m = protoDouble(m, 4, t.Idle)
+	m = protoDouble(m, 5, t.IOWait)
+	m = protoDouble(m, 6, t.Steal)
+	m = protoDouble(m, 7, t.Nice)
+	m = protoDouble(m, 8, t.IRQ)
+	m = protoDouble(m, 9, t.SoftIRQ)
+	return protoDouble(m, 10, t.Guest)
+}
+
+// protoNetworkCounters encodes a NetworkCounters message
+func protoNetworkCounters(bytesSent, bytesRecv, packetsSent, packetsRecv, errIn, errOut, dropIn, dropOut uint64) []byte {
+	m := protoUint(nil, 1, bytesSent)
+	m = protoUint(m, 2, bytesRecv)
+	m = protoUint(m, 3, packetsSent)
+	m = protoUint(m, 4, packetsRecv)
+	m = protoUint(m, 5, errIn)
+	m = protoUint(m, 6, errOut)
+	m = protoUint(m, 7, dropIn)
+	return protoUint(m, 8, dropOut)
+}
+
+// The helpers below append a single field, skipping zero scalars the way
+// proto3 does
+
+func protoString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func protoUint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// protoInt encodes an int32 or int64 field; negative values take ten bytes
+func protoInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func protoDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func protoBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func protoPackedDoubles(b []byte, num protowire.Number, vs []float64) []byte {
+	if len(vs) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(len(vs)*8))
+	for _, v := range vs {
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+// protoMessage appends an embedded message, even an empty one, so the field
+// is present
+func protoMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// protoMapEntry appends one map entry; value holds the already encoded value
+// field (number 2)
+func protoMapEntry(b []byte, num protowire.Number, key string, value []byte) []byte {
+	entry := protoString(nil, 1, key)
+	return protoMessage(b, num, append(entry, value...))
+}
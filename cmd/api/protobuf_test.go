@@ -0,0 +1,392 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TestMarshalVitalsProtoRoundTrip decodes the hand-written encoding with a
+// descriptor built from proto/vitals.proto and checks every field against the
+// JSON payload, so field numbers and types can't drift from the schema
+func TestMarshalVitalsProtoRoundTrip(t *testing.T) {
+	file := parseProtoFile(t, "../../proto/vitals.proto")
+	desc := file.Messages().ByName("SystemVitals")
+	if desc == nil {
+		t.Fatal("proto/vitals.proto has no SystemVitals message")
+	}
+
+	vitals := newSystemVitals()
+	fillValue(reflect.ValueOf(vitals).Elem(), new(int))
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(marshalVitalsProto(vitals, nil), msg); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+
+	// The JSON payload names fields like the schema, in camel case
+	raw, err := json.Marshal(vitals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]any)
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	if err := dec.Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	want["id"] = json.Number(strconv.FormatUint(vitals.ID, 10))
+	want["lastUpdated"] = json.Number(strconv.FormatInt(vitals.LastUpdated.UnixMilli(), 10))
+
+	compareProtoMessage(t, "SystemVitals", msg, want)
+}
+
+func TestMarshalHistoryProtoRoundTrip(t *testing.T) {
+	file := parseProtoFile(t, "../../proto/vitals.proto")
+	desc := file.Messages().ByName("HistorySample")
+	if desc == nil {
+		t.Fatal("proto/vitals.proto has no HistorySample message")
+	}
+
+	var sample HistorySample
+	fillValue(reflect.ValueOf(&sample).Elem(), new(int))
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(marshalHistoryProto(sample), msg); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+
+	raw, err := json.Marshal(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]any)
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	if err := dec.Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	want["timestamp"] = json.Number(strconv.FormatInt(sample.Timestamp.UnixMilli(), 10))
+
+	compareProtoMessage(t, "HistorySample", msg, want)
+}
+
+// parseProtoFile builds a descriptor from the subset of the proto3 language
+// vitals.proto uses: top-level messages with scalar, message, repeated and
+// map fields
+func parseProtoFile(t *testing.T, path string) protoreflect.FileDescriptor {
+	t.Helper()
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comments := regexp.MustCompile(`//[^\n]*`)
+	tokens := regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*|\d+|"[^"]*"|[{}<>=;,]`).
+		FindAllString(comments.ReplaceAllString(string(src), ""), -1)
+
+	next := func(want ...string) string {
+		t.Helper()
+		if len(tokens) == 0 {
+			t.Fatalf("%s: unexpected end of file", path)
+		}
+		tok := tokens[0]
+		tokens = tokens[1:]
+		if len(want) > 0 && !slices.Contains(want, tok) {
+			t.Fatalf("%s: got %q, want one of %q", path, tok, want)
+		}
+		return tok
+	}
+	number := func() int32 {
+		t.Helper()
+		n, err := strconv.ParseInt(next(), 10, 32)
+		if err != nil {
+			t.Fatalf("%s: field number: %v", path, err)
+		}
+		return int32(n)
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("vitals.proto"),
+		Syntax: proto.String("proto3"),
+	}
+	for len(tokens) > 0 {
+		switch next("syntax", "package", "message") {
+		case "syntax":
+			next("=")
+			next(`"proto3"`)
+			next(";")
+		case "package":
+			file.Package = proto.String(next())
+			next(";")
+		case "message":
+			msg := &descriptorpb.DescriptorProto{Name: proto.String(next())}
+			next("{")
+			for tokens[0] != "}" {
+				field := &descriptorpb.FieldDescriptorProto{
+					Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				}
+
+				switch typ := next(); typ {
+				case "map":
+					next("<")
+					key := next()
+					next(",")
+					value := next()
+					next(">")
+					field.Name = proto.String(next())
+
+					entry := &descriptorpb.DescriptorProto{
+						Name:    proto.String(protoCamelCase(field.GetName()) + "Entry"),
+						Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+						Field: []*descriptorpb.FieldDescriptorProto{
+							protoFieldType(&descriptorpb.FieldDescriptorProto{Name: proto.String("key"), Number: proto.Int32(1)}, key, file),
+							protoFieldType(&descriptorpb.FieldDescriptorProto{Name: proto.String("value"), Number: proto.Int32(2)}, value, file),
+						},
+					}
+					for _, f := range entry.Field {
+						f.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+					}
+					msg.NestedType = append(msg.NestedType, entry)
+
+					field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+					field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+					field.TypeName = proto.String("." + file.GetPackage() + "." + msg.GetName() + "." + entry.GetName())
+				case "repeated":
+					field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+					protoFieldType(field, next(), file)
+					field.Name = proto.String(next())
+				default:
+					protoFieldType(field, typ, file)
+					field.Name = proto.String(next())
+				}
+
+				next("=")
+				field.Number = proto.Int32(number())
+				next(";")
+				msg.Field = append(msg.Field, field)
+			}
+			next("}")
+			file.MessageType = append(file.MessageType, msg)
+		}
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	return fd
+}
+
+// protoFieldType sets the type of field from its name in the schema
+func protoFieldType(field *descriptorpb.FieldDescriptorProto, typ string, file *descriptorpb.FileDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	if kind, ok := descriptorpb.FieldDescriptorProto_Type_value["TYPE_"+strings.ToUpper(typ)]; ok && typ != "group" && typ != "message" && typ != "enum" {
+		field.Type = descriptorpb.FieldDescriptorProto_Type(kind).Enum()
+		return field
+	}
+
+	field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	field.TypeName = proto.String("." + file.GetPackage() + "." + typ)
+
+	return field
+}
+
+// protoCamelCase turns a snake_case field name into CamelCase
+func protoCamelCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+
+	return b.String()
+}
+
+// jsonFieldAliases names the schema fields the JSON payload calls something
+// else
+var jsonFieldAliases = map[protoreflect.FullName]string{
+	"homeservervitals.Temperature.temperature": "sensorTemperature", // named by gopsutil
+}
+
+// jsonFieldName is the JSON payload's name for a schema field, which is the
+// camel case name with initialisms kept upper case
+func jsonFieldName(want map[string]any, fd protoreflect.FieldDescriptor) (string, bool) {
+	if alias, ok := jsonFieldAliases[fd.FullName()]; ok {
+		_, found := want[alias]
+		return alias, found
+	}
+
+	key := strings.ReplaceAll(string(fd.Name()), "_", "")
+	for k := range want {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// compareProtoMessage checks every field the schema declares against the
+// decoded JSON payload, and that nothing was left undecoded
+func compareProtoMessage(t *testing.T, path string, msg protoreflect.Message, want map[string]any) {
+	t.Helper()
+
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("%s: %d bytes of fields missing from the schema", path, len(unknown))
+	}
+
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := path + "." + string(fd.Name())
+
+		var value any
+		if key, ok := jsonFieldName(want, fd); ok {
+			value = want[key]
+		} else if fd.Kind() == protoreflect.MessageKind && !fd.IsList() {
+			// Nested in the schema but flattened into the parent in JSON,
+			// like an interface's counters
+			value = want
+		} else {
+			t.Errorf("%s: no matching field in the JSON payload", fieldPath)
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			entries, _ := value.(map[string]any)
+			got := msg.Get(fd).Map()
+			if got.Len() != len(entries) {
+				t.Errorf("%s: %d entries, want %d", fieldPath, got.Len(), len(entries))
+				continue
+			}
+			for k, v := range entries {
+				mk := protoreflect.ValueOfString(k).MapKey()
+				if !got.Has(mk) {
+					t.Errorf("%s[%q]: missing", fieldPath, k)
+					continue
+				}
+				compareProtoValue(t, fmt.Sprintf("%s[%q]", fieldPath, k), fd.MapValue(), got.Get(mk), v)
+			}
+		case fd.IsList():
+			items, _ := value.([]any)
+			got := msg.Get(fd).List()
+			if got.Len() != len(items) {
+				t.Errorf("%s: %d items, want %d", fieldPath, got.Len(), len(items))
+				continue
+			}
+			for i, v := range items {
+				compareProtoValue(t, fmt.Sprintf("%s[%d]", fieldPath, i), fd, got.Get(i), v)
+			}
+		default:
+			compareProtoValue(t, fieldPath, fd, msg.Get(fd), value)
+		}
+	}
+}
+
+// compareProtoValue checks one decoded value, treating a missing JSON value
+// as the zero value proto3 leaves off the wire
+func compareProtoValue(t *testing.T, path string, fd protoreflect.FieldDescriptor, got protoreflect.Value, want any) {
+	t.Helper()
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		fields, _ := want.(map[string]any)
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		compareProtoMessage(t, path, got.Message(), fields)
+	case protoreflect.StringKind:
+		s, _ := want.(string)
+		if got.String() != s {
+			t.Errorf("%s = %q, want %q", path, got.String(), s)
+		}
+	case protoreflect.BoolKind:
+		b, _ := want.(bool)
+		if got.Bool() != b {
+			t.Errorf("%s = %t, want %t", path, got.Bool(), b)
+		}
+	case protoreflect.DoubleKind, protoreflect.FloatKind:
+		n, _ := want.(json.Number)
+		f, _ := n.Float64()
+		if math.Abs(got.Float()-f) > 1e-9 {
+			t.Errorf("%s = %v, want %v", path, got.Float(), f)
+		}
+	case protoreflect.Uint64Kind, protoreflect.Uint32Kind, protoreflect.Fixed64Kind, protoreflect.Fixed32Kind:
+		n, _ := want.(json.Number)
+		u, _ := strconv.ParseUint(cmp.Or(string(n), "0"), 10, 64)
+		if got.Uint() != u {
+			t.Errorf("%s = %d, want %d", path, got.Uint(), u)
+		}
+	default:
+		n, _ := want.(json.Number)
+		i, _ := strconv.ParseInt(cmp.Or(string(n), "0"), 10, 64)
+		if got.Int() != i {
+			t.Errorf("%s = %d, want %d", path, got.Int(), i)
+		}
+	}
+}
+
+// fillValue sets every exported field reachable from v to a distinct non-zero
+// value, so a value written under the wrong field number can't go unnoticed
+func fillValue(v reflect.Value, seq *int) {
+	*seq++
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*seq))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*seq))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(*seq) + 0.5)
+	case reflect.String:
+		v.SetString("s" + strconv.Itoa(*seq))
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillValue(v.Elem(), seq)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fillValue(v.Index(i), seq)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 2; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			fillValue(key, seq)
+			elem := reflect.New(v.Type().Elem()).Elem()
+			fillValue(elem, seq)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.UnixMilli(int64(*seq) * 1000).UTC()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fillValue(v.Field(i), seq)
+			}
+		}
+	}
+}
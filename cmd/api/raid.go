@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const mdstatPath = "/proc/mdstat"
+
+// RaidInfo contains the state of a Linux software RAID (md) array
+type RaidInfo struct {
+	Device      string `json:"device"` // e.g. md0
+	Level       string `json:"level"`  // raid1, raid5, ..., empty for inactive arrays
+	State       string `json:"state"`  // active, inactive, active (auto-read-only), ...
+	ActiveDisks int    `json:"activeDisks"`
+	TotalDisks  int    `json:"totalDisks"`
+	FailedDisks int    `json:"failedDisks"` // members marked (F)
+}
+
+// degraded reports whether the array has a failed or missing member
+func (r RaidInfo) degraded() bool {
+	return r.FailedDisks > 0 || r.ActiveDisks < r.TotalDisks
+}
+
+// mdstatArray matches an array's first line, e.g. "md0 : active raid1 sdb1[1] sda1[0]"
+var mdstatArray = regexp.MustCompile(`^(md\S+)\s*:\s*(.*)$`)
+
+// mdstatDisks matches the "[total/active]" member count on the line after it
+var mdstatDisks = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+
+// collectRaidArrays reads every md array from /proc/mdstat. Hosts without
+// software RAID, and other platforms, get an empty list.
+func collectRaidArrays(vitals *SystemVitals, enabled bool) {
+	vitals.RaidArrays = make([]RaidInfo, 0)
+	if !enabled {
+		return
+	}
+
+	data, err := os.ReadFile(mdstatPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		vitals.collectFailed("RAID", err)
+		return
+	}
+
+	vitals.RaidArrays = parseMdstat(data)
+}
+
+// parseMdstat parses the contents of /proc/mdstat
+func parseMdstat(data []byte) []RaidInfo {
+	arrays := make([]RaidInfo, 0)
+
+	var current *RaidInfo
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := mdstatArray.FindStringSubmatch(line); m != nil {
+			arrays = append(arrays, parseMdstatArray(m[1], strings.Fields(m[2])))
+			current = &arrays[len(arrays)-1]
+			continue
+		}
+
+		// Arrays without redundancy, such as raid0 and linear, have no member
+		// count and keep the one counted from their devices
+		if current != nil {
+			if m := mdstatDisks.FindStringSubmatch(line); m != nil {
+				current.TotalDisks, _ = strconv.Atoi(m[1])
+				current.ActiveDisks, _ = strconv.Atoi(m[2])
+				current = nil
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			current = nil
+		}
+	}
+
+	return arrays
+}
+
+// parseMdstatArray parses the fields after "mdX :", which are the state, any
+// parenthesized qualifiers, the level and then the members, e.g.
+// "active (auto-read-only) raid1 sdb1[1] sda1[0](F)"
+func parseMdstatArray(device string, fields []string) RaidInfo {
+	array := RaidInfo{Device: device}
+	if len(fields) == 0 {
+		return array
+	}
+
+	array.State = fields[0]
+	fields = fields[1:]
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "(") {
+		array.State += " " + fields[0]
+		fields = fields[1:]
+	}
+
+	// Inactive arrays list their members without a level
+	if len(fields) > 0 && !strings.Contains(fields[0], "[") {
+		array.Level = fields[0]
+		fields = fields[1:]
+	}
+
+	for _, member := range fields {
+		switch {
+		case strings.HasSuffix(member, "(F)"):
+			array.FailedDisks++
+			array.TotalDisks++
+		case strings.HasSuffix(member, "(S)"):
+			// Spares don't count towards the array's members
+		default:
+			array.ActiveDisks++
+			array.TotalDisks++
+		}
+	}
+
+	return array
+}
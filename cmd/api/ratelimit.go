@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sseLimiterSweepInterval is how often idle IPs are forgotten
+const sseLimiterSweepInterval = time.Minute
+
+// sseLimiter caps concurrent SSE streams per client IP and throttles how fast
+// an IP can open new ones, so a misbehaving client can't exhaust the server
+type sseLimiter struct {
+	maxPerIP int
+
+	mu        sync.Mutex
+	clients   map[string]*sseClient
+	lastSweep time.Time
+}
+
+type sseClient struct {
+	active  int
+	limiter *rate.Limiter
+}
+
+func newSSELimiter(maxPerIP int) *sseLimiter {
+	return &sseLimiter{
+		maxPerIP: maxPerIP,
+		clients:  make(map[string]*sseClient),
+	}
+}
+
+// acquire reserves a stream slot for ip, reporting false when the IP is at its
+// concurrent limit or reconnecting too quickly
+func (l *sseLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now(); now.Sub(l.lastSweep) >= sseLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	client, ok := l.clients[ip]
+	if !ok {
+		// Allow a burst of maxPerIP connections, then one new connection per second
+		client = &sseClient{limiter: rate.NewLimiter(rate.Every(time.Second), l.maxPerIP)}
+		l.clients[ip] = client
+	}
+
+	if client.active >= l.maxPerIP || !client.limiter.Allow() {
+		return false
+	}
+
+	client.active++
+
+	return true
+}
+
+// release frees a slot taken by acquire
+func (l *sseLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, ok := l.clients[ip]
+	if !ok {
+		return
+	}
+
+	client.active--
+
+	// Forget idle IPs once their limiter has refilled. Those released with a
+	// drained bucket are kept, so quick reconnects stay throttled, until a
+	// later sweep.
+	if client.active <= 0 && client.limiter.Tokens() >= float64(l.maxPerIP) {
+		delete(l.clients, ip)
+	}
+}
+
+// sweep forgets every IP without open streams whose limiter has refilled by
+// now, so the map only holds recently active clients. The caller holds l.mu.
+func (l *sseLimiter) sweep(now time.Time) {
+	for ip, client := range l.clients {
+		if client.active <= 0 && client.limiter.TokensAt(now) >= float64(l.maxPerIP) {
+			delete(l.clients, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+// sseLimitMiddleware rejects SSE connections over the per-IP limit with 429.
+// It relies on middleware.RealIP having already resolved r.RemoteAddr.
+func (app *application) sseLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+
+		if !app.sseClients.acquire(ip) {
+			app.rateLimitExceededResponse(w, r, errors.New("too many SSE connections from "+ip))
+			return
+		}
+		defer app.sseClients.release(ip)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP strips the port from r.RemoteAddr when present
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSSELimiterForgetsIdleIPs(t *testing.T) {
+	l := newSSELimiter(3)
+
+	// Connect and disconnect quickly, leaving the bucket partly drained
+	if !l.acquire("192.0.2.10") {
+		t.Fatal("first connection rejected")
+	}
+	l.release("192.0.2.10")
+	if _, ok := l.clients["192.0.2.10"]; !ok {
+		t.Fatal("drained client forgotten before its bucket refilled")
+	}
+
+	l.mu.Lock()
+	l.sweep(time.Now().Add(sseLimiterSweepInterval))
+	l.mu.Unlock()
+
+	if n := len(l.clients); n != 0 {
+		t.Fatalf("%d clients kept after sweep, want 0", n)
+	}
+}
+
+func TestSSELimiterSweepKeepsActiveIPs(t *testing.T) {
+	l := newSSELimiter(3)
+	l.acquire("192.0.2.10")
+
+	l.mu.Lock()
+	l.sweep(time.Now().Add(sseLimiterSweepInterval))
+	l.mu.Unlock()
+
+	if _, ok := l.clients["192.0.2.10"]; !ok {
+		t.Fatal("client with an open stream was forgotten")
+	}
+}
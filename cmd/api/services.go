@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceCacheTTL bounds how often systemctl is run for the same services
+const serviceCacheTTL = 10 * time.Second
+
+// ServiceInfo contains the state of a monitored systemd unit
+type ServiceInfo struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`    // active, inactive, failed, not-found, ...
+	SubState string `json:"subState"` // running, exited, dead, ...
+	PID      int32  `json:"pid"`
+	Error    string `json:"error,omitempty"`
+}
+
+// serviceCache keeps the last systemctl results so collection passes shorter
+// than serviceCacheTTL reuse them
+type serviceCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	results []ServiceInfo
+}
+
+// collectServices reports the state of each configured systemd unit. It is a
+// no-op off Linux or when no services are configured.
+func collectServices(ctx context.Context, vitals *SystemVitals, services []string, cache *serviceCache) {
+	vitals.Services = make([]ServiceInfo, 0, len(services))
+	if len(services) == 0 || runtime.GOOS != "linux" {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.results == nil || time.Since(cache.fetched) >= serviceCacheTTL {
+		results := make([]ServiceInfo, 0, len(services))
+		for _, name := range services {
+			results = append(results, queryService(ctx, name))
+		}
+		cache.results = results
+		cache.fetched = time.Now()
+	}
+
+	vitals.Services = append(vitals.Services, cache.results...)
+}
+
+// queryService reads a unit's state with `systemctl show`. Its ActiveState
+// property is what `systemctl is-active` prints, so one call covers both.
+func queryService(ctx context.Context, name string) ServiceInfo {
+	info := ServiceInfo{Name: name, State: "unknown"}
+
+	output, err := exec.CommandContext(ctx, "systemctl", "show", name,
+		"--property=LoadState,ActiveState,SubState,MainPID").Output()
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[key] = value
+		}
+	}
+
+	if props["LoadState"] == "not-found" {
+		info.State = "not-found"
+		return info
+	}
+
+	if state := props["ActiveState"]; state != "" {
+		info.State = state
+	}
+	info.SubState = props["SubState"]
+
+	if pid, err := strconv.ParseInt(props["MainPID"], 10, 32); err == nil {
+		info.PID = int32(pid)
+	}
+
+	return info
+}
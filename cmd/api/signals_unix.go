@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// processSignals are the signals that can be sent through the API, by name
+// without the SIG prefix
+var processSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"STOP": syscall.SIGSTOP,
+	"TSTP": syscall.SIGTSTP,
+	"CONT": syscall.SIGCONT,
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// processSignals are the signals that can be sent through the API. Windows
+// can only terminate processes, so the job control and user signals are
+// missing.
+var processSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
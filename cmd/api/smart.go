@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+// SmartInfo contains SMART health for a physical drive
+type SmartInfo struct {
+	Device             string  `json:"device"`
+	Model              string  `json:"model"`
+	Health             string  `json:"health"` // PASSED, FAILED or UNKNOWN
+	ReallocatedSectors uint64  `json:"reallocatedSectors"`
+	Temperature        float64 `json:"temperature"`
+	Error              string  `json:"error,omitempty"`
+}
+
+// smartctlOutput is the subset of `smartctl --json` output we use
+type smartctlOutput struct {
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	ATASmartAttributes struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	Smartctl struct {
+		Messages []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+}
+
+// ATA attribute 5, Reallocated_Sector_Ct
+const smartReallocatedSectors = 5
+
+// partitionSuffix strips the partition number from sdX1, vdX1, nvme0n1p1 and
+// mmcblk0p1 style device names
+var partitionSuffix = regexp.MustCompile(`^(/dev/(?:[shv]d[a-z]+|xvd[a-z]+|nvme\d+n\d+|mmcblk\d+))(?:p?\d+)?$`)
+
+var (
+	smartctlOnce sync.Once
+	smartctlPath string
+)
+
+func lookupSmartctl() string {
+	smartctlOnce.Do(func() {
+		path, err := exec.LookPath("smartctl")
+		if err != nil {
+			slog.Info("smartctl not found, SMART monitoring disabled")
+			return
+		}
+		smartctlPath = path
+	})
+
+	return smartctlPath
+}
+
+// collectSmartHealth queries smartctl for every physical drive backing a
+// mounted partition
+func collectSmartHealth(ctx context.Context, vitals *SystemVitals, enabled bool) {
+	vitals.SmartHealth = make([]SmartInfo, 0)
+	if !enabled {
+		return
+	}
+
+	path := lookupSmartctl()
+	if path == "" {
+		return
+	}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		vitals.collectFailed("SMART", err)
+		return
+	}
+
+	for _, device := range physicalDevices(partitions) {
+		vitals.SmartHealth = append(vitals.SmartHealth, querySmart(ctx, path, device))
+	}
+}
+
+// physicalDevices maps partitions to their unique parent drives
+func physicalDevices(partitions []disk.PartitionStat) []string {
+	seen := make(map[string]struct{})
+	for _, part := range partitions {
+		if m := partitionSuffix.FindStringSubmatch(part.Device); m != nil {
+			seen[m[1]] = struct{}{}
+		}
+	}
+
+	devices := make([]string, 0, len(seen))
+	for device := range seen {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	return devices
+}
+
+func querySmart(ctx context.Context, path, device string) SmartInfo {
+	info := SmartInfo{Device: device, Health: "UNKNOWN"}
+
+	// smartctl's exit status is a bitmask that is non-zero for failing drives
+	// too, so the JSON is parsed whenever there is any
+	output, err := exec.CommandContext(ctx, path, "-H", "-A", "--json", device).Output()
+	if len(output) == 0 {
+		if err != nil {
+			info.Error = err.Error()
+		}
+		return info
+	}
+
+	var parsed smartctlOutput
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Model = parsed.ModelName
+	info.Temperature = parsed.Temperature.Current
+
+	if parsed.SmartStatus != nil {
+		info.Health = "FAILED"
+		if parsed.SmartStatus.Passed {
+			info.Health = "PASSED"
+		}
+	} else {
+		// Typically a permissions problem: smartctl needs root to open the device
+		for _, msg := range parsed.Smartctl.Messages {
+			if msg.Severity == "error" {
+				info.Error = msg.String
+				break
+			}
+		}
+	}
+
+	for _, attr := range parsed.ATASmartAttributes.Table {
+		if attr.ID == smartReallocatedSectors {
+			info.ReallocatedSectors = attr.Raw.Value
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const (
+	snapshotModeAppend = "append-jsonl"
+	snapshotModeLatest = "latest-only"
+)
+
+type snapshotConfig struct {
+	path     string
+	mode     string
+	maxBytes int64 // rotate the append file past this size, 0 disables rotation
+}
+
+// snapshotWriter persists collector snapshots to a file, either appending one
+// JSON object per line or replacing the file with the latest snapshot
+type snapshotWriter struct {
+	cfg snapshotConfig
+}
+
+func newSnapshotWriter(cfg snapshotConfig) (*snapshotWriter, error) {
+	if cfg.mode != snapshotModeAppend && cfg.mode != snapshotModeLatest {
+		return nil, fmt.Errorf("mode must be %q or %q", snapshotModeAppend, snapshotModeLatest)
+	}
+
+	return &snapshotWriter{cfg: cfg}, nil
+}
+
+// run writes every snapshot the collector publishes until ctx is cancelled
+func (s *snapshotWriter) run(ctx context.Context, c *collector) {
+	updates := c.subscribe()
+	defer c.unsubscribe(updates)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case vitals := <-updates:
+			if err := s.write(vitals); err != nil {
+				slog.Warn("writing snapshot file failed", "path", s.cfg.path, "error", err)
+			}
+		}
+	}
+}
+
+func (s *snapshotWriter) write(vitals *SystemVitals) error {
+	data, err := json.Marshal(vitals)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.mode == snapshotModeLatest {
+		return writeFileAtomic(s.cfg.path, data)
+	}
+
+	if err := s.rotate(int64(len(data) + 1)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.cfg.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// rotate moves the append file to <path>.1, replacing any earlier rotation,
+// when adding pending bytes would take it past the size limit
+func (s *snapshotWriter) rotate(pending int64) error {
+	if s.cfg.maxBytes <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(s.cfg.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if info.Size() == 0 || info.Size()+pending <= s.cfg.maxBytes {
+		return nil
+	}
+
+	return os.Rename(s.cfg.path, s.cfg.path+".1")
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// source provides the raw readings behind the core metrics. The collectors
+// only aggregate, filter and sort what it returns, so a fake source can stand
+// in for the machine when exercising that logic.
+type source interface {
+	CPUPercent(ctx context.Context, sample time.Duration) ([]float64, error) // per core
+	VirtualMemory(ctx context.Context) (*mem.VirtualMemoryStat, error)
+	SwapMemory(ctx context.Context) (*mem.SwapMemoryStat, error)
+	Partitions(ctx context.Context) ([]disk.PartitionStat, error)
+	DiskUsage(ctx context.Context, path string) (*disk.UsageStat, error)
+	DiskIOCounters(ctx context.Context) (map[string]disk.IOCountersStat, error)
+	NetIOCounters(ctx context.Context) ([]net.IOCountersStat, error) // per interface
+	NetInterfaces(ctx context.Context) ([]net.InterfaceStat, error)
+	LoadAvg(ctx context.Context) (*load.AvgStat, error)
+	Processes(ctx context.Context) ([]*process.Process, error)
+}
+
+// hostSource reads the running system through gopsutil
+type hostSource struct{}
+
+func (hostSource) CPUPercent(ctx context.Context, sample time.Duration) ([]float64, error) {
+	return cpu.PercentWithContext(ctx, sample, true)
+}
+
+func (hostSource) VirtualMemory(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+	return mem.VirtualMemoryWithContext(ctx)
+}
+
+func (hostSource) SwapMemory(ctx context.Context) (*mem.SwapMemoryStat, error) {
+	return mem.SwapMemoryWithContext(ctx)
+}
+
+func (hostSource) Partitions(ctx context.Context) ([]disk.PartitionStat, error) {
+	return disk.PartitionsWithContext(ctx, false)
+}
+
+func (hostSource) DiskUsage(ctx context.Context, path string) (*disk.UsageStat, error) {
+	return disk.UsageWithContext(ctx, path)
+}
+
+func (hostSource) DiskIOCounters(ctx context.Context) (map[string]disk.IOCountersStat, error) {
+	return disk.IOCountersWithContext(ctx)
+}
+
+func (hostSource) NetIOCounters(ctx context.Context) ([]net.IOCountersStat, error) {
+	return net.IOCountersWithContext(ctx, true)
+}
+
+func (hostSource) NetInterfaces(ctx context.Context) ([]net.InterfaceStat, error) {
+	return net.InterfacesWithContext(ctx)
+}
+
+func (hostSource) LoadAvg(ctx context.Context) (*load.AvgStat, error) {
+	return load.AvgWithContext(ctx)
+}
+
+func (hostSource) Processes(ctx context.Context) ([]*process.Process, error) {
+	return process.ProcessesWithContext(ctx)
+}
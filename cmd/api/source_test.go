@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// fakeSource returns fixed readings, so collection can be exercised without
+// depending on the machine running the tests. Nil fields return empty results.
+type fakeSource struct {
+	cpuPercent []float64
+	memory     *mem.VirtualMemoryStat
+	swap       *mem.SwapMemoryStat
+	partitions []disk.PartitionStat
+	usage      map[string]*disk.UsageStat // by mountpoint
+	diskIO     map[string]disk.IOCountersStat
+	netIO      []net.IOCountersStat
+	interfaces []net.InterfaceStat
+	loadAvg    *load.AvgStat
+	processes  []*process.Process
+	err        error // returned by every call when set
+}
+
+func (f *fakeSource) CPUPercent(ctx context.Context, sample time.Duration) ([]float64, error) {
+	return f.cpuPercent, f.err
+}
+
+func (f *fakeSource) VirtualMemory(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+	if f.memory == nil {
+		return &mem.VirtualMemoryStat{}, f.err
+	}
+	return f.memory, f.err
+}
+
+func (f *fakeSource) SwapMemory(ctx context.Context) (*mem.SwapMemoryStat, error) {
+	if f.swap == nil {
+		return &mem.SwapMemoryStat{}, f.err
+	}
+	return f.swap, f.err
+}
+
+func (f *fakeSource) Partitions(ctx context.Context) ([]disk.PartitionStat, error) {
+	return f.partitions, f.err
+}
+
+func (f *fakeSource) DiskUsage(ctx context.Context, path string) (*disk.UsageStat, error) {
+	if usage, ok := f.usage[path]; ok {
+		return usage, f.err
+	}
+	return &disk.UsageStat{Path: path}, f.err
+}
+
+func (f *fakeSource) DiskIOCounters(ctx context.Context) (map[string]disk.IOCountersStat, error) {
+	return f.diskIO, f.err
+}
+
+func (f *fakeSource) NetIOCounters(ctx context.Context) ([]net.IOCountersStat, error) {
+	return f.netIO, f.err
+}
+
+func (f *fakeSource) NetInterfaces(ctx context.Context) ([]net.InterfaceStat, error) {
+	return f.interfaces, f.err
+}
+
+func (f *fakeSource) LoadAvg(ctx context.Context) (*load.AvgStat, error) {
+	if f.loadAvg == nil {
+		return &load.AvgStat{}, f.err
+	}
+	return f.loadAvg, f.err
+}
+
+func (f *fakeSource) Processes(ctx context.Context) ([]*process.Process, error) {
+	return f.processes, f.err
+}
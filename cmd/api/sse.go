@@ -1,15 +1,28 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/host"
@@ -29,64 +42,179 @@ type DiskInfo struct {
 	UsedPercent float64 `json:"usedPercent"`
 }
 
+// DiskIOStat contains I/O counters for a block device and the mountpoints it
+// backs, either directly or through one of its partitions
+type DiskIOStat struct {
+	disk.IOCountersStat
+	Mountpoints []string `json:"mountpoints"`
+}
+
 // NetworkInterface contains network interface information
 type NetworkInterface struct {
-	Name      string `json:"name"`
-	IPAddress string `json:"ipAddress"`
-	MacAddr   string `json:"macAddr"`
-	BytesSent uint64 `json:"bytesSent"`
-	BytesRecv uint64 `json:"bytesRecv"`
-	IsUp      bool   `json:"isUp"`
+	Name      string   `json:"name"`
+	IPAddress string   `json:"ipAddress"` // preferred address: IPv4 when available, otherwise IPv6
+	IPv4      string   `json:"ipv4"`
+	IPv6      string   `json:"ipv6"`
+	Addresses []string `json:"addresses"` // every address in CIDR notation
+	MacAddr   string   `json:"macAddr"`
+	BytesSent uint64   `json:"bytesSent"`
+	BytesRecv uint64   `json:"bytesRecv"`
+	IsUp      bool     `json:"isUp"`
+	SpeedMbps int64    `json:"speedMbps,omitempty"` // negotiated link speed, omitted when unknown
+
+	PacketsSent uint64  `json:"packetsSent"`
+	PacketsRecv uint64  `json:"packetsRecv"`
+	ErrIn       uint64  `json:"errIn"`
+	ErrOut      uint64  `json:"errOut"`
+	DropIn      uint64  `json:"dropIn"`
+	DropOut     uint64  `json:"dropOut"`
+	ErrInRate   float64 `json:"errInRate"` // per second since the previous collection
+	ErrOutRate  float64 `json:"errOutRate"`
+	DropInRate  float64 `json:"dropInRate"`
+	DropOutRate float64 `json:"dropOutRate"`
 }
 
 // TopProcess contains information about top resource-consuming processes
 type TopProcess struct {
-	PID     int32   `json:"pid"`
-	Name    string  `json:"name"`
-	CPU     float64 `json:"cpu"`
-	Memory  float64 `json:"memory"`
-	Command string  `json:"command"`
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	CPU        float64 `json:"cpu"`
+	Memory     float64 `json:"memory"`
+	RSS        uint64  `json:"rss"`        // resident bytes
+	VMS        uint64  `json:"vms"`        // virtual bytes
+	Shared     uint64  `json:"shared"`     // resident bytes shared with other processes, Linux only
+	Exe        string  `json:"exe"`        // binary path, empty when it can't be read
+	Command    string  `json:"command"`    // truncated to CMDLINE_MAX characters
+	User       string  `json:"user"`       // empty when the owner can't be read
+	OpenFiles  int32   `json:"openFiles"`  // open file descriptors, 0 when they can't be read
+	StartTime  int64   `json:"startTime"`  // unix milliseconds, 0 when unknown
+	RunningFor uint64  `json:"runningFor"` // seconds, 0 when unknown
 }
 
 // HardwareInfo contains detailed hardware information
 type HardwareInfo struct {
-	CPUModel     string `json:"cpuModel"`
-	CPUCores     int    `json:"cpuCores"`
-	CPUThreads   int    `json:"cpuThreads"`
-	TotalMemory  uint64 `json:"totalMemory"`
-	SystemVendor string `json:"systemVendor"`
-	SystemModel  string `json:"systemModel"`
+	CPUModel      string  `json:"cpuModel"`
+	CPUCores      int     `json:"cpuCores"`
+	CPUThreads    int     `json:"cpuThreads"`
+	CPUFreqMaxMHz float64 `json:"cpuFreqMaxMHz"`
+	TotalMemory   uint64  `json:"totalMemory"`
+	SystemVendor  string  `json:"systemVendor"`
+	SystemModel   string  `json:"systemModel"`
 }
 
 // SystemVitals contains all system metrics
 type SystemVitals struct {
-	CPUUsage      float64                        `json:"cpuUsage"`
-	CPUPerCore    []float64                      `json:"cpuPerCore"`
-	Memory        *mem.VirtualMemoryStat         `json:"memory"`
-	Swap          *mem.SwapMemoryStat            `json:"swap"`
-	Disks         []DiskInfo                     `json:"disks"`
-	Network       net.IOCountersStat             `json:"network"`
-	NetworkIfaces []NetworkInterface             `json:"networkIfaces"`
-	HostInfo      *host.InfoStat                 `json:"hostInfo"`
-	Uptime        uint64                         `json:"uptime"`
-	LoadAvg       *load.AvgStat                  `json:"loadAvg"`
-	Processes     int                            `json:"processes"`
-	Temperature   []host.TemperatureStat         `json:"temperature"`
-	GoRoutines    int                            `json:"goRoutines"`
-	GoMemAlloc    uint64                         `json:"goMemAlloc"`
-	TopProcesses  []TopProcess                   `json:"topProcesses"`
-	Hardware      HardwareInfo                   `json:"hardware"`
-	LastUpdated   time.Time                      `json:"lastUpdated"`
-	SystemUpdates int                            `json:"systemUpdates"`
-	DiskIO        map[string]disk.IOCountersStat `json:"diskIO"`
+	ID                   uint64                 `json:"-"` // collection sequence number, used as the SSE event ID
+	CPUUsage             float64                `json:"cpuUsage"`
+	CPUPerCore           []float64              `json:"cpuPerCore"`
+	CPUTimes             []CPUTimesInfo         `json:"cpuTimes"`     // per-core time split since the previous collection
+	CPUBreakdown         CPUTimesInfo           `json:"cpuBreakdown"` // the same split across all cores
+	CPUFreqMHz           float64                `json:"cpuFreqMHz"`   // current clock speed averaged across cores
+	Memory               *mem.VirtualMemoryStat `json:"memory"`
+	Swap                 *mem.SwapMemoryStat    `json:"swap"`
+	SwapInRate           float64                `json:"swapInRate"`  // bytes/s paged in since the previous collection
+	SwapOutRate          float64                `json:"swapOutRate"` // bytes/s paged out since the previous collection
+	Disks                []DiskInfo             `json:"disks"`
+	Network              net.IOCountersStat     `json:"network"`
+	NetworkIfaces        []NetworkInterface     `json:"networkIfaces"`
+	Connections          ConnectionStats        `json:"connections"`
+	HostInfo             *host.InfoStat         `json:"hostInfo"`
+	Uptime               uint64                 `json:"uptime"`
+	AppUptime            uint64                 `json:"appUptime"`            // seconds since this service started
+	BootTime             uint64                 `json:"bootTime"`             // unix seconds
+	VirtualizationSystem string                 `json:"virtualizationSystem"` // e.g. kvm, docker, empty on bare metal
+	VirtualizationRole   string                 `json:"virtualizationRole"`   // host or guest
+	Users                []UserSession          `json:"users"`
+	LoadAvg              *load.AvgStat          `json:"loadAvg"`
+	LoadPerCore          float64                `json:"loadPerCore"` // Load1 divided by hardware threads, 1 means fully busy
+	Processes            int                    `json:"processes"`
+	ProcessStates        map[string]int         `json:"processStates"` // counts by state letter: R, S, D, Z, T, I...
+	Zombies              int                    `json:"zombies"`
+	OpenFileDescriptors  uint64                 `json:"openFileDescriptors"`
+	MaxFileDescriptors   uint64                 `json:"maxFileDescriptors"`
+	Temperature          []host.TemperatureStat `json:"temperature"`
+	CPUPackageTemps      []PackageTemp          `json:"cpuPackageTemps"` // coretemp sensors grouped by CPU package
+	GoRoutines           int                    `json:"goRoutines"`
+	GoMemAlloc           uint64                 `json:"goMemAlloc"`
+	TopProcesses         []TopProcess           `json:"topProcesses"`
+	Hardware             HardwareInfo           `json:"hardware"`
+	LastUpdated          time.Time              `json:"lastUpdated"`
+	SystemUpdates        int                    `json:"systemUpdates"`
+	DiskIO               map[string]DiskIOStat  `json:"diskIO"`
+	Alerts               []Alert                `json:"alerts"`
+	GPU                  []GPUInfo              `json:"gpu"`
+	Containers           []ContainerInfo        `json:"containers"`
+	Battery              []BatteryInfo          `json:"battery"`
+	TempUnit             string                 `json:"tempUnit"`
+	SmartHealth          []SmartInfo            `json:"smartHealth"`
+	ZFSPools             []ZFSPoolInfo          `json:"zfsPools"`
+	RaidArrays           []RaidInfo             `json:"raidArrays"`
+	Services             []ServiceInfo          `json:"services"`
+	JournalErrorRate     float64                `json:"journalErrorRate"` // journal entries of priority err or worse per minute
+	Connectivity         *ConnectivityInfo      `json:"connectivity"`     // nil unless CONNECTIVITY_MONITORING is enabled
+	PingTargets          []PingResult           `json:"pingTargets"`
+	NumaNodes            []NumaInfo             `json:"numaNodes"` // empty on single node machines
+
+	// MemoryActualUsedPercent is (Total - Available) / Total. Memory.Used,
+	// and so Memory.UsedPercent, is computed differently per platform and can
+	// include page cache; Available is the kernel's estimate of memory that can
+	// be handed out without swapping, so this is the share applications hold.
+	MemoryActualUsedPercent float64 `json:"memoryActualUsedPercent"`
+
+	// MemoryCommitted is the memory promised to every process combined
+	// (Committed_AS) and MemoryCommitLimit the most strict overcommit would
+	// allow (CommitLimit). A CommitRatio nearing or over 1 means allocations
+	// outstrip what can be backed, so an OOM kill is likely under pressure.
+	// All three are zero off Linux.
+	MemoryCommitted   uint64  `json:"memoryCommitted"`
+	MemoryCommitLimit uint64  `json:"memoryCommitLimit"`
+	CommitRatio       float64 `json:"commitRatio"`
+
+	// CollectionErrors maps each metric that failed this pass to its error, so
+	// a zero value can be told apart from a failed collection
+	CollectionErrors map[string]string `json:"collectionErrors"`
+
+	// netRecvRate and netSendRate are the bytes per second across the reported
+	// interfaces since the previous network collection, kept for history
+	netRecvRate float64
+	netSendRate float64
 }
 
 func (app *application) initiateSSE(w http.ResponseWriter, r *http.Request) {
+	// Payload shaping requested through query parameters
+	opts, err := app.parsePayloadOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	opts.encoding, err = parseEncoding(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Opt-in delta frames, restarted with a keyframe on every connection
+	var delta *deltaEncoder
+	if raw := r.URL.Query().Get("delta"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("delta must be true or false"))
+			return
+		}
+		if enabled && opts.encoding != "" {
+			app.badRequestResponse(w, r, errors.New("delta is only supported with JSON encoding"))
+			return
+		}
+		if enabled {
+			delta = newDeltaEncoder(app.config.sse.keyframeEvery)
+		}
+	}
+
 	// Set appropriate headers for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// Check for flusher capability
 	flusher, ok := w.(http.Flusher)
@@ -96,43 +224,130 @@ func (app *application) initiateSSE(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register client disconnect detection
+	clientID := uuid.NewString()
 	notify := r.Context().Done()
-	go func() {
-		<-notify
-		log.Println("Client disconnected")
-	}()
 
-	// Send SSE data at regular intervals
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	connected := app.sseConnected.Add(1)
+	defer app.sseConnected.Add(-1)
+
+	slog.Info("client connected",
+		"clientId", clientID,
+		"requestId", middleware.GetReqID(r.Context()),
+		"remote", r.RemoteAddr,
+		"connectedClients", connected,
+	)
+	defer slog.Info("client disconnected", "clientId", clientID)
+
+	// Receive every snapshot from the shared collector
+	updates := app.collector.subscribe()
+	defer app.collector.unsubscribe(updates)
+
+	// Send the latest data immediately, if there is any yet. A reconnecting
+	// client first gets the buffered history it missed.
+	if vitals := app.collector.snapshot(); vitals != nil {
+		if lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			replayHistory(w, app.collector.history.since(lastID), vitals.ID, opts.encoding)
+		}
+		sendVitalsData(w, flusher, vitals, opts, delta)
+	}
+	lastSent := time.Now()
 
-	// Send initial data immediately
-	sendVitalsData(w, flusher)
+	// Keep idle connections warm behind proxies that cull quiet streams
+	keepAlive := time.NewTicker(app.config.sse.keepAlive)
+	defer keepAlive.Stop()
 
-	// Keep sending data until client disconnects
+	// Keep sending data until the client disconnects or the server shuts down
 	for {
 		select {
 		case <-notify:
 			return
-		case <-ticker.C:
-			sendVitalsData(w, flusher)
+		case <-app.shutdown:
+			// Tell the client this isn't a network failure before closing
+			fmt.Fprint(w, "event: close\ndata: server closing\n\n")
+			flusher.Flush()
+			return
+		case vitals := <-updates:
+			sendVitalsData(w, flusher, vitals, opts, delta)
+			lastSent = time.Now()
+		case <-keepAlive.C:
+			if time.Since(lastSent) < app.config.sse.keepAlive {
+				continue
+			}
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				slog.Warn("writing to client", "error", err)
+				continue
+			}
+			flusher.Flush()
+			lastSent = time.Now()
 		}
 	}
 }
 
-func sendVitalsData(w http.ResponseWriter, flusher http.Flusher) {
-	vitals := collectSystemVitals()
+// parseEncoding reads the payload encoding from the `encoding` query
+// parameter, or failing that the Accept header. JSON is the default.
+func parseEncoding(r *http.Request) (string, error) {
+	switch encoding := r.URL.Query().Get("encoding"); encoding {
+	case "", "json":
+	case encodingProtobuf, encodingMsgpack:
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("encoding must be %q, %q or %q", "json", encodingProtobuf, encodingMsgpack)
+	}
 
-	jsonData, err := json.Marshal(vitals)
-	if err != nil {
-		log.Printf("Error marshalling JSON: %v", err)
-		return
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "application/x-protobuf", "application/protobuf":
+			return encodingProtobuf, nil
+		case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+			return encodingMsgpack, nil
+		}
+	}
+
+	return "", nil
+}
+
+// sendVitalsData writes vitals as an SSE event. With a delta encoder, frames
+// between keyframes are sent as `delta` events holding only the changed fields.
+func sendVitalsData(w http.ResponseWriter, flusher http.Flusher, vitals *SystemVitals, opts payloadOptions, delta *deltaEncoder) {
+	var data []byte
+	switch opts.encoding {
+	case encodingProtobuf:
+		payload := marshalVitalsProto(convertTemperatures(vitals, opts.tempUnit), opts.fields)
+		data = base64.StdEncoding.AppendEncode(nil, payload)
+	case encodingMsgpack:
+		payload, err := marshalMsgpack(opts.apply(vitals))
+		if err != nil {
+			slog.Error("marshalling vitals", "error", err)
+			return
+		}
+		data = base64.StdEncoding.AppendEncode(nil, payload)
+	default:
+		var err error
+		data, err = json.Marshal(opts.apply(vitals))
+		if err != nil {
+			slog.Error("marshalling vitals", "error", err)
+			return
+		}
+	}
+
+	event := ""
+	if delta != nil {
+		frame, keyframe, err := delta.encode(data)
+		if err != nil {
+			slog.Error("encoding vitals delta", "error", err)
+			return
+		}
+		data = frame
+		if !keyframe {
+			event = "event: delta\n"
+		}
 	}
 
 	// Write the SSE data format
-	_, err = fmt.Fprintf(w, "data: %s\n\n", jsonData)
+	_, err := fmt.Fprintf(w, "%sid: %d\ndata: %s\n\n", event, vitals.ID, data)
 	if err != nil {
-		log.Printf("Error writing to client: %v", err)
+		slog.Warn("writing to client", "error", err)
 		return
 	}
 
@@ -140,50 +355,236 @@ func sendVitalsData(w http.ResponseWriter, flusher http.Flusher) {
 	flusher.Flush()
 }
 
-func collectSystemVitals() *SystemVitals {
-	vitals := &SystemVitals{
-		LastUpdated: time.Now(),
+// replayHistory writes buffered samples older than the current snapshot as
+// `history` events, so a reconnecting EventSource can fill the gap. Samples use
+// the stream's encoding. The caller flushes once the live snapshot follows.
+func replayHistory(w http.ResponseWriter, samples []HistorySample, currentID uint64, encoding string) {
+	for _, sample := range samples {
+		if sample.ID >= currentID {
+			break
+		}
+
+		var data []byte
+		switch encoding {
+		case encodingProtobuf:
+			data = base64.StdEncoding.AppendEncode(nil, marshalHistoryProto(sample))
+		case encodingMsgpack:
+			payload, err := marshalMsgpack(sample)
+			if err != nil {
+				slog.Error("marshalling history sample", "error", err)
+				return
+			}
+			data = base64.StdEncoding.AppendEncode(nil, payload)
+		default:
+			var err error
+			data, err = json.Marshal(sample)
+			if err != nil {
+				slog.Error("marshalling history sample", "error", err)
+				return
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: history\ndata: %s\n\n", sample.ID, data); err != nil {
+			slog.Warn("writing to client", "error", err)
+			return
+		}
 	}
+}
 
-	// CPU Usage (total and per core)
-	cpuPercents, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		log.Printf("CPU Usage: %v", err)
-	} else if len(cpuPercents) > 0 {
-		vitals.CPUUsage = cpuPercents[0]
+// collectOptions tunes a full collection pass
+type collectOptions struct {
+	cpuSample  time.Duration
+	interfaces nameFilter
+	disks      diskOptions
+	processes  processOptions
+	docker     dockerOptions
+	smart      bool
+	zfs        bool
+	raid       bool
+	journal    bool
+	services   []string
+
+	// temperature is TEMPERATURE_ENABLED. When nil, sensors are probed once
+	// and temperatures are skipped if there are none.
+	temperature *bool
+
+	connectivity connectivityOptions
+	ping         pingOptions
+}
+
+// processOptions controls which processes are reported in TopProcesses
+type processOptions struct {
+	sortBy string // processSortCPU or processSortMemory
+	limit  int
+	filter string // case-insensitive substring of the name or command line
+
+	// cpuMin and memMin are the CPU and memory percentages a process must
+	// exceed, either one, to be reported
+	cpuMin float64
+	memMin float64
+
+	// cmdlineMax truncates reported command lines to this many characters,
+	// 0 for no limit. The filter matches the full command line.
+	cmdlineMax int
+}
+
+const (
+	processSortCPU    = "cpu"
+	processSortMemory = "memory"
+)
+
+// maxTopProcesses caps how many top processes can be requested
+const maxTopProcesses = 50
+
+func collectSystemVitals(ctx context.Context, src source, opts collectOptions, state *collectState) *SystemVitals {
+	vitals := newSystemVitals()
+	for _, group := range metricGroups {
+		group.collect(ctx, src, opts, state, vitals)
 	}
+	finishSystemVitals(vitals)
 
-	// CPU Usage per core
-	perCore, err := cpu.Percent(time.Second, true)
-	if err != nil {
-		log.Printf("CPU Per Core: %v", err)
+	return vitals
+}
+
+// newSystemVitals returns an empty snapshot stamped with the current time
+func newSystemVitals() *SystemVitals {
+	return &SystemVitals{
+		LastUpdated:      time.Now(),
+		TempUnit:         tempUnitCelsius,
+		CollectionErrors: make(map[string]string),
+	}
+}
+
+// finishSystemVitals fills in the values that are cheap or cached elsewhere,
+// once every metric group has been collected or merged
+func finishSystemVitals(vitals *SystemVitals) {
+	// Hardware Info (static, collected once)
+	vitals.Hardware = cachedHardwareInfo()
+
+	// System Updates Available (checked in the background)
+	vitals.SystemUpdates = cachedUpdateCount()
+
+	// Go Runtime Metrics
+	vitals.GoRoutines = runtime.NumGoroutine()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	vitals.GoMemAlloc = memStats.Alloc
+}
+
+// collectHost gathers host information, uptime and logged in users
+func collectHost(ctx context.Context, vitals *SystemVitals) {
+	// Host Information
+	if hostInfo, err := host.InfoWithContext(ctx); err != nil {
+		vitals.collectFailed("Host Info", err)
 	} else {
-		vitals.CPUPerCore = perCore
+		vitals.HostInfo = hostInfo
+		vitals.BootTime = hostInfo.BootTime
+		vitals.VirtualizationSystem = hostInfo.VirtualizationSystem
+		vitals.VirtualizationRole = hostInfo.VirtualizationRole
 	}
 
+	// Uptime
+	if uptime, err := host.UptimeWithContext(ctx); err != nil {
+		vitals.collectFailed("Uptime", err)
+	} else {
+		vitals.Uptime = uptime
+	}
+
+	collectUsers(ctx, vitals)
+}
+
+// collectCPU samples per-core CPU usage over the given window and derives the
+// total as the average across cores, so only one blocking sample is taken
+func collectCPU(ctx context.Context, src source, vitals *SystemVitals, sample time.Duration) {
+	perCore, err := src.CPUPercent(ctx, sample)
+	if err != nil {
+		vitals.collectFailed("CPU Usage", err)
+		return
+	}
+
+	vitals.CPUPerCore = perCore
+
+	if len(perCore) > 0 {
+		var total float64
+		for _, pct := range perCore {
+			total += pct
+		}
+		vitals.CPUUsage = total / float64(len(perCore))
+	}
+}
+
+// collectMemory gathers virtual memory and swap usage
+func collectMemory(ctx context.Context, src source, vitals *SystemVitals) {
 	// Memory Usage
-	if memory, err := mem.VirtualMemory(); err != nil {
-		log.Printf("Memory: %v", err)
+	if memory, err := src.VirtualMemory(ctx); err != nil {
+		vitals.collectFailed("Memory", err)
 	} else {
 		vitals.Memory = memory
+		if memory.Total > 0 && memory.Available <= memory.Total {
+			vitals.MemoryActualUsedPercent = float64(memory.Total-memory.Available) / float64(memory.Total) * 100
+		}
+		if memory.CommitLimit > 0 {
+			vitals.MemoryCommitted = memory.CommittedAS
+			vitals.MemoryCommitLimit = memory.CommitLimit
+			vitals.CommitRatio = float64(memory.CommittedAS) / float64(memory.CommitLimit)
+		}
 	}
 
 	// Swap Usage
-	if swap, err := mem.SwapMemory(); err != nil {
-		log.Printf("Swap: %v", err)
+	if swap, err := src.SwapMemory(ctx); err != nil {
+		vitals.collectFailed("Swap", err)
 	} else {
 		vitals.Swap = swap
 	}
+}
+
+// diskOptions selects the partitions and block devices reported
+type diskOptions struct {
+	mounts   nameFilter // by mountpoint
+	pseudoFS bool       // also report pseudoFilesystems mounted elsewhere than /
+	io       nameFilter // by device name
+}
+
+// pseudoFilesystems are memory-backed or layered filesystems that aren't
+// storage of their own. They are skipped unless mounted at /, which is
+// overlay inside a container.
+var pseudoFilesystems = map[string]bool{
+	"tmpfs":    true,
+	"devtmpfs": true,
+	"overlay":  true,
+	"squashfs": true,
+}
+
+// reports says whether a partition's usage should be reported
+func (o diskOptions) reports(part disk.PartitionStat) bool {
+	if !o.pseudoFS && pseudoFilesystems[part.Fstype] && part.Mountpoint != "/" {
+		return false
+	}
 
+	return o.mounts.allows(part.Mountpoint)
+}
+
+// collectDisks gathers partition usage and I/O counters for the partitions
+// and block devices allowed by opts
+func collectDisks(ctx context.Context, src source, vitals *SystemVitals, opts diskOptions) {
 	// Disk Usage (all partitions)
-	partitions, err := disk.Partitions(false)
+	partitions, err := src.Partitions(ctx)
 	if err != nil {
-		log.Printf("Disk Partitions: %v", err)
+		vitals.collectFailed("Disk Partitions", err)
 	} else {
 		vitals.Disks = make([]DiskInfo, 0, len(partitions))
 		for _, part := range partitions {
-			usage, err := disk.Usage(part.Mountpoint)
+			if !opts.reports(part) {
+				continue
+			}
+
+			usage, err := diskUsage(ctx, src, part.Mountpoint)
 			if err != nil {
+				if ctx.Err() != nil {
+					vitals.collectFailed("Disk Usage", err)
+					break
+				}
+				vitals.recordError("Disk Usage "+part.Mountpoint, err)
 				continue
 			}
 
@@ -200,226 +601,564 @@ func collectSystemVitals() *SystemVitals {
 	}
 
 	// Disk I/O stats
-	diskIO, err := disk.IOCounters()
+	diskIO, err := src.DiskIOCounters(ctx)
 	if err != nil {
-		log.Printf("Disk IO: %v", err)
-	} else {
-		vitals.DiskIO = diskIO
+		vitals.collectFailed("Disk IO", err)
+		return
 	}
 
+	mounts := deviceMountpoints(partitions)
+	vitals.DiskIO = make(map[string]DiskIOStat, len(diskIO))
+	for name, counters := range diskIO {
+		if !opts.io.allows(name) {
+			continue
+		}
+		vitals.DiskIO[name] = DiskIOStat{
+			IOCountersStat: counters,
+			Mountpoints:    mounts[name],
+		}
+	}
+}
+
+// deviceMountpoints maps device names, as used by the I/O counters, to their
+// mountpoints. A partition's mountpoints are listed under the partition and
+// under its parent drive, so nvme0n1 reports / when nvme0n1p2 is mounted there.
+func deviceMountpoints(partitions []disk.PartitionStat) map[string][]string {
+	mounts := make(map[string][]string)
+	for _, part := range partitions {
+		if !strings.HasPrefix(part.Device, "/dev/") {
+			continue
+		}
+
+		name := filepath.Base(part.Device)
+		mounts[name] = append(mounts[name], part.Mountpoint)
+
+		if m := partitionSuffix.FindStringSubmatch(part.Device); m != nil {
+			if parent := filepath.Base(m[1]); parent != name {
+				mounts[parent] = append(mounts[parent], part.Mountpoint)
+			}
+		}
+	}
+
+	return mounts
+}
+
+// collectNetwork gathers aggregate and per-interface network I/O for the
+// interfaces allowed by the filter, with error and drop rates measured since
+// the tracker's previous pass
+func collectNetwork(ctx context.Context, src source, vitals *SystemVitals, filter nameFilter, tracker *interfaceTracker) {
 	// Network I/O (sum all interfaces)
-	if netIO, err := net.IOCounters(true); err != nil {
-		log.Printf("Network: %v", err)
+	if netIO, err := src.NetIOCounters(ctx); err != nil {
+		vitals.collectFailed("Network", err)
 	} else {
 		var total net.IOCountersStat
-
-		// Collect network interfaces with IP addresses
-		ifaces, _ := net.Interfaces()
+		counters := make(map[string]net.IOCountersStat, len(netIO))
+
+		// Index the interfaces by name once, so each counter is joined with
+		// its own interface's addresses and flags
+		ifaces, _ := src.NetInterfaces(ctx)
+		byName := make(map[string]net.InterfaceStat, len(ifaces))
+		for _, iface := range ifaces {
+			byName[iface.Name] = iface
+		}
 		vitals.NetworkIfaces = make([]NetworkInterface, 0, len(ifaces))
 
 		for _, io := range netIO {
+			if !filter.allows(io.Name) {
+				continue
+			}
+
+			counters[io.Name] = io
 			total.BytesSent += io.BytesSent
 			total.BytesRecv += io.BytesRecv
+			total.PacketsSent += io.PacketsSent
+			total.PacketsRecv += io.PacketsRecv
+			total.Errin += io.Errin
+			total.Errout += io.Errout
+			total.Dropin += io.Dropin
+			total.Dropout += io.Dropout
+
+			iface, ok := byName[io.Name]
+			if !ok {
+				continue
+			}
 
-			// Find matching interface to get IP
-			for _, iface := range ifaces {
-				if iface.Name == io.Name {
-					netIface := NetworkInterface{
-						Name:      io.Name,
-						MacAddr:   iface.HardwareAddr,
-						BytesSent: io.BytesSent,
-						BytesRecv: io.BytesRecv,
-						IsUp:      true, // Simplified
-					}
-
-					// Try to get IP address from interface name
-					for _, addr := range ifaces {
-						if addr.Name == iface.Name && len(addr.Addrs) > 0 {
-							netIface.IPAddress = addr.Addrs[0].Addr
-							break
-						}
-					}
-
-					vitals.NetworkIfaces = append(vitals.NetworkIfaces, netIface)
-					break
-				}
+			netIface := NetworkInterface{
+				Name:      io.Name,
+				MacAddr:   iface.HardwareAddr,
+				BytesSent: io.BytesSent,
+				BytesRecv: io.BytesRecv,
+				IsUp:      hasFlag(iface.Flags, "up"),
+				SpeedMbps: linkSpeed(io.Name),
+
+				PacketsSent: io.PacketsSent,
+				PacketsRecv: io.PacketsRecv,
+				ErrIn:       io.Errin,
+				ErrOut:      io.Errout,
+				DropIn:      io.Dropin,
+				DropOut:     io.Dropout,
 			}
+			setInterfaceAddresses(&netIface, iface.Addrs)
+
+			vitals.NetworkIfaces = append(vitals.NetworkIfaces, netIface)
 		}
 		vitals.Network = total
+		vitals.netRecvRate, vitals.netSendRate = tracker.setRates(vitals.NetworkIfaces, counters, vitals.LastUpdated)
 	}
+}
 
-	// Host Information
-	if hostInfo, err := host.Info(); err != nil {
-		log.Printf("Host Info: %v", err)
-	} else {
-		vitals.HostInfo = hostInfo
+// setInterfaceAddresses records every address of an interface and picks the
+// preferred IPv4 and IPv6 ones: non-loopback over loopback, and for IPv6
+// global over link-local
+func setInterfaceAddresses(iface *NetworkInterface, addrs []net.InterfaceAddr) {
+	iface.Addresses = make([]string, 0, len(addrs))
+
+	var v4, v6 netip.Addr
+	for _, a := range addrs {
+		iface.Addresses = append(iface.Addresses, a.Addr)
+
+		prefix, err := netip.ParsePrefix(a.Addr)
+		if err != nil {
+			continue
+		}
+		ip := prefix.Addr()
+
+		if ip.Is4() || ip.Is4In6() {
+			if !v4.IsValid() || (v4.IsLoopback() && !ip.IsLoopback()) {
+				v4 = ip.Unmap()
+			}
+			continue
+		}
+
+		if !v6.IsValid() || addrRank(ip) > addrRank(v6) {
+			v6 = ip
+		}
 	}
 
-	// Hardware Info
-	vitals.Hardware = collectHardwareInfo()
+	if v4.IsValid() {
+		iface.IPv4 = v4.String()
+		iface.IPAddress = iface.IPv4
+	}
+	if v6.IsValid() {
+		iface.IPv6 = v6.String()
+		if iface.IPAddress == "" {
+			iface.IPAddress = iface.IPv6
+		}
+	}
+}
 
-	// Uptime
-	if uptime, err := host.Uptime(); err != nil {
-		log.Printf("Uptime: %v", err)
-	} else {
-		vitals.Uptime = uptime
+// addrRank orders IPv6 addresses by preference: global, link-local, loopback
+func addrRank(ip netip.Addr) int {
+	switch {
+	case ip.IsLoopback():
+		return 0
+	case ip.IsLinkLocalUnicast():
+		return 1
+	default:
+		return 2
 	}
+}
 
-	// Load Average
-	if loadAvg, err := load.Avg(); err != nil {
-		log.Printf("Load Average: %v", err)
-	} else {
-		vitals.LoadAvg = loadAvg
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
 	}
 
-	// Process Count
-	if processes, err := process.Processes(); err != nil {
-		log.Printf("Processes: %v", err)
-	} else {
-		vitals.Processes = len(processes)
-
-		// Get top processes by CPU and memory
-		topProcesses := make([]TopProcess, 0, 5)
-		for _, p := range processes {
-			cpuPercent, _ := p.CPUPercent()
-			memPercent, _ := p.MemoryPercent()
-			name, _ := p.Name()
-			cmdline, _ := p.Cmdline()
-
-			// Only include processes with non-zero CPU usage
-			if cpuPercent > 0 {
-				topProc := TopProcess{
-					PID:     p.Pid,
-					Name:    name,
-					CPU:     cpuPercent,
-					Memory:  float64(memPercent),
-					Command: cmdline,
-				}
+	return false
+}
+
+// collectLoad gathers the 1, 5 and 15 minute load averages and the 1 minute
+// average per hardware thread
+func collectLoad(ctx context.Context, src source, vitals *SystemVitals) {
+	loadAvg, err := src.LoadAvg(ctx)
+	if err != nil {
+		vitals.collectFailed("Load Average", err)
+		return
+	}
+
+	vitals.LoadAvg = loadAvg
+	if threads := cachedHardwareInfo().CPUThreads; threads > 0 {
+		vitals.LoadPerCore = loadAvg.Load1 / float64(threads)
+	}
+}
+
+// collectProcesses counts processes and records the top consumers, ordered by
+// the requested metric. CPU usage is measured since the tracker's previous
+// pass; a tracker without history is primed and sampled over a short window.
+func collectProcesses(ctx context.Context, src source, vitals *SystemVitals, opts processOptions, tracker *processTracker) {
+	processes, err := src.Processes(ctx)
+	if err != nil {
+		vitals.collectFailed("Processes", err)
+		return
+	}
+
+	vitals.Processes = len(processes)
+	states := make(map[string]int)
 
-				topProcesses = append(topProcesses, topProc)
+	if tracker.empty() {
+		tracker.track(processes)
+		select {
+		case <-ctx.Done():
+			vitals.collectFailed("Processes", ctx.Err())
+			return
+		case <-time.After(processWarmup):
+		}
+	}
+	processes = tracker.track(processes)
+	filter := strings.ToLower(opts.filter)
+
+	// Memory percentages are taken against the cached total rather than
+	// through MemoryPercent, which reads the system memory for every process
+	totalMemory := cachedHardwareInfo().TotalMemory
+
+	// On macOS the state comes from running ps once per process, too slow to
+	// do for all of them every pass
+	countStates := runtime.GOOS != "darwin"
+
+	// Get top processes by CPU and memory
+	candidates := make([]TopProcess, 0, len(processes))
+	handles := make(map[int32]*process.Process, len(processes))
+	for _, p := range processes {
+		if ctx.Err() != nil {
+			vitals.collectFailed("Processes", ctx.Err())
+			break
+		}
+
+		if countStates {
+			if state, err := p.StatusWithContext(ctx); err == nil && state != "" {
+				states[state]++
 			}
 		}
 
-		// Sort by CPU usage (descending)
-		for i := 0; i < len(topProcesses)-1; i++ {
-			for j := i + 1; j < len(topProcesses); j++ {
-				if topProcesses[i].CPU < topProcesses[j].CPU {
-					topProcesses[i], topProcesses[j] = topProcesses[j], topProcesses[i]
-				}
+		cpuPercent, _ := p.PercentWithContext(ctx, 0)
+		var memPercent float64
+		var rss, vms uint64
+		if memInfo, err := p.MemoryInfoWithContext(ctx); err == nil {
+			rss, vms = memInfo.RSS, memInfo.VMS
+			if totalMemory > 0 {
+				memPercent = float64(rss) / float64(totalMemory) * 100
 			}
 		}
 
-		// Keep only top 5
-		if len(topProcesses) > 5 {
-			topProcesses = topProcesses[:5]
+		top := TopProcess{
+			PID:    p.Pid,
+			CPU:    cpuPercent,
+			Memory: memPercent,
+			RSS:    rss,
+			VMS:    vms,
 		}
 
-		vitals.TopProcesses = topProcesses
+		// Restrict to processes matching the name filter, if any
+		if filter != "" {
+			top.Name, _ = p.NameWithContext(ctx)
+			top.Command, _ = p.CmdlineWithContext(ctx)
+			if !strings.Contains(strings.ToLower(top.Name), filter) &&
+				!strings.Contains(strings.ToLower(top.Command), filter) {
+				continue
+			}
+		}
+
+		candidates = append(candidates, top)
+		handles[p.Pid] = p
 	}
 
-	// Temperature Sensors
-	if temps, err := host.SensorsTemperatures(); err != nil {
-		log.Printf("Temperature: %v", err)
+	vitals.ProcessStates = states
+	vitals.Zombies = states["Z"]
+
+	topProcesses := selectTopProcesses(candidates, opts)
+
+	// The remaining details each cost at least one /proc read, and the owner a
+	// user database lookup, so they are only resolved for the processes that
+	// are reported. Processes of other users may not be readable.
+	for i := range topProcesses {
+		top := &topProcesses[i]
+		p := handles[top.PID]
+
+		if filter == "" {
+			top.Name, _ = p.NameWithContext(ctx)
+			top.Command, _ = p.CmdlineWithContext(ctx)
+		}
+		top.Command = truncateCommand(top.Command, opts.cmdlineMax)
+		top.Exe, _ = p.ExeWithContext(ctx)
+		if created, err := p.CreateTimeWithContext(ctx); err == nil && created > 0 {
+			top.StartTime = created
+			if running := time.Since(time.UnixMilli(created)); running > 0 {
+				top.RunningFor = uint64(running.Seconds())
+			}
+		}
+		if user, err := p.UsernameWithContext(ctx); err == nil {
+			top.User = user
+		}
+		if fds, err := p.NumFDsWithContext(ctx); err == nil {
+			top.OpenFiles = fds
+		}
+		top.Shared = sharedMemory(ctx, p)
+	}
+
+	vitals.TopProcesses = topProcesses
+}
+
+// truncateCommand shortens a command line to at most limit characters,
+// ending it with an ellipsis. A limit of 0 leaves it whole.
+func truncateCommand(command string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(command) <= limit {
+		return command
+	}
+
+	runes := []rune(command)
+	return string(runes[:limit-1]) + "…"
+}
+
+// selectTopProcesses keeps the candidates above either threshold, or all of
+// them when none are so an idle host still lists its heaviest processes, and
+// returns the top opts.limit of those by the sort metric
+func selectTopProcesses(candidates []TopProcess, opts processOptions) []TopProcess {
+	top := slices.DeleteFunc(slices.Clone(candidates), func(p TopProcess) bool {
+		return p.CPU <= opts.cpuMin && p.Memory <= opts.memMin
+	})
+	if len(top) == 0 {
+		top = slices.Clone(candidates)
+	}
+
+	sortProcesses(top, opts.sortBy)
+
+	// Keep only the top N
+	if opts.limit > 0 && len(top) > opts.limit {
+		top = top[:opts.limit]
+	}
+
+	return top
+}
+
+// sortProcesses orders processes by the given metric, descending, and ties by
+// PID. Unknown metrics fall back to CPU.
+func sortProcesses(processes []TopProcess, sortBy string) {
+	if sortBy == processSortMemory {
+		slices.SortFunc(processes, func(a, b TopProcess) int {
+			return cmp.Or(cmp.Compare(b.Memory, a.Memory), cmp.Compare(a.PID, b.PID))
+		})
+		return
+	}
+
+	slices.SortFunc(processes, func(a, b TopProcess) int {
+		return cmp.Or(cmp.Compare(b.CPU, a.CPU), cmp.Compare(a.PID, b.PID))
+	})
+}
+
+// collectTemperatures reads the host's temperature sensors, in Celsius. It
+// reports no sensors without querying when disabled by TEMPERATURE_ENABLED,
+// or when unset and the startup probe found none.
+func collectTemperatures(ctx context.Context, vitals *SystemVitals, enabled *bool) {
+	disabled := enabled != nil && !*enabled
+	if disabled || enabled == nil && !sensorsAvailable {
+		vitals.Temperature = make([]host.TemperatureStat, 0)
+		vitals.CPUPackageTemps = make([]PackageTemp, 0)
+		return
+	}
+
+	if temps, err := host.SensorsTemperaturesWithContext(ctx); err != nil {
+		vitals.collectFailed("Temperature", err)
 	} else {
 		vitals.Temperature = temps
+		vitals.CPUPackageTemps = groupPackageTemps(temps)
 	}
+}
 
-	// System Updates Available
-	vitals.SystemUpdates = checkForUpdates()
+// diskUsage wraps the source's usage lookup so a hung mount (e.g. a dead NFS
+// server) cannot block past the collection deadline. The stat call itself may
+// stay blocked in the background until the kernel gives up.
+func diskUsage(ctx context.Context, src source, path string) (*disk.UsageStat, error) {
+	type result struct {
+		usage *disk.UsageStat
+		err   error
+	}
 
-	// Go Runtime Metrics
-	vitals.GoRoutines = runtime.NumGoroutine()
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	vitals.GoMemAlloc = memStats.Alloc
+	done := make(chan result, 1)
+	go func() {
+		usage, err := src.DiskUsage(ctx, path)
+		done <- result{usage, err}
+	}()
 
-	return vitals
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-done:
+		return res.usage, res.err
+	}
+}
+
+// collectFailed logs a failed sub-collector and records it in the payload
+func (v *SystemVitals) collectFailed(metric string, err error) {
+	logCollectError(metric, err)
+	v.recordError(metric, err)
+}
+
+// recordError records a failure in the payload without logging it, for
+// expected per-item failures that would otherwise flood the log
+func (v *SystemVitals) recordError(metric string, err error) {
+	if v.CollectionErrors == nil {
+		v.CollectionErrors = make(map[string]string)
+	}
+	v.CollectionErrors[metric] = err.Error()
+}
+
+// logCollectError logs a failed sub-collector with the metric as a field
+func logCollectError(metric string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		slog.Warn("collection skipped, deadline exceeded", "metric", metric, "error", err)
+		return
+	}
+
+	slog.Warn("collection failed", "metric", metric, "error", err)
+}
+
+// hardwareTimeout bounds the one-off hardware lookup
+const hardwareTimeout = 10 * time.Second
+
+var (
+	hardwareOnce  sync.Once
+	hardwareCache atomic.Pointer[HardwareInfo]
+)
+
+// cachedHardwareInfo returns hardware details collected on first use. CPU
+// model, core counts and system vendor rarely change at runtime, so they are
+// kept out of the per-tick collection; refreshHardwareInfo picks up hotplug.
+func cachedHardwareInfo() HardwareInfo {
+	hardwareOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), hardwareTimeout)
+		defer cancel()
+
+		info := collectHardwareInfo(ctx)
+		hardwareCache.Store(&info)
+	})
+
+	return *hardwareCache.Load()
+}
+
+// refreshHardwareInfo collects the hardware details again and replaces the
+// cached ones, unless ctx ended before the lookup finished
+func refreshHardwareInfo(ctx context.Context) (HardwareInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, hardwareTimeout)
+	defer cancel()
+
+	info := collectHardwareInfo(ctx)
+	if err := ctx.Err(); err != nil {
+		return HardwareInfo{}, err
+	}
+
+	// A refresh before first use stands in for the initial lookup
+	hardwareOnce.Do(func() {})
+	hardwareCache.Store(&info)
+
+	return info, nil
+}
+
+// refreshHardwareHandler re-reads the hardware details after a change such as
+// added memory or CPUs, and responds with them
+func (app *application) refreshHardwareHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := refreshHardwareInfo(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	slog.Info("hardware info refreshed", "requestId", middleware.GetReqID(r.Context()))
+	app.writeMetric(w, &info)
 }
 
 // collectHardwareInfo gathers detailed hardware information
-func collectHardwareInfo() HardwareInfo {
+func collectHardwareInfo(ctx context.Context) HardwareInfo {
 	info := HardwareInfo{}
 
 	// CPU Info
-	cpuInfo, err := cpu.Info()
+	cpuInfo, err := cpu.InfoWithContext(ctx)
 	if err == nil && len(cpuInfo) > 0 {
 		info.CPUModel = cpuInfo[0].ModelName
 	}
 
 	// CPU Cores/Threads
-	counts, err := cpu.Counts(true)
+	counts, err := cpu.CountsWithContext(ctx, true)
 	if err == nil {
 		info.CPUThreads = counts
 	}
 
-	counts, err = cpu.Counts(false)
+	counts, err = cpu.CountsWithContext(ctx, false)
 	if err == nil {
 		info.CPUCores = counts
 	}
 
+	info.CPUFreqMaxMHz = cpuMaxFreq(ctx)
+
 	// Memory Total
-	mem, err := mem.VirtualMemory()
+	mem, err := mem.VirtualMemoryWithContext(ctx)
 	if err == nil {
 		info.TotalMemory = mem.Total
 	}
 
 	// Try to get system vendor/model (Linux only)
-	info.SystemVendor = getCommandOutput("cat /sys/devices/virtual/dmi/id/sys_vendor 2>/dev/null || echo 'Unknown'")
-	info.SystemModel = getCommandOutput("cat /sys/devices/virtual/dmi/id/product_name 2>/dev/null || echo 'Unknown'")
+	info.SystemVendor = dmiField("sys_vendor")
+	info.SystemModel = dmiField("product_name")
 
 	return info
 }
 
-// checkForUpdates counts available system updates
-func checkForUpdates() int {
-	updates := 0
+// dmiField reads a DMI attribute such as sys_vendor, or returns "Unknown" when
+// it isn't available (non-Linux systems, some VMs and ARM boards)
+func dmiField(name string) string {
+	if value := readSysString("/sys/devices/virtual/dmi/id/" + name); value != "" {
+		return value
+	}
 
-	// Check for different package managers
-	if runtime.GOOS == "linux" {
-		// apt (Debian/Ubuntu)
-		aptUpdates := getCommandOutput("apt list --upgradable 2>/dev/null | grep -v 'Listing...' | wc -l")
-		if aptNum, err := parseCommandInt(aptUpdates); err == nil && aptNum > 0 {
-			updates = aptNum
-		}
+	return "Unknown"
+}
 
-		// yum/dnf (RHEL/CentOS/Fedora)
-		if updates == 0 {
-			yumUpdates := getCommandOutput("yum check-update --quiet | grep -v '^$' | wc -l")
-			if yumNum, err := parseCommandInt(yumUpdates); err == nil {
-				updates = yumNum
-			}
-		}
-	} else if runtime.GOOS == "darwin" {
-		// macOS (rough estimate using softwareupdate)
-		macUpdates := getCommandOutput("softwareupdate -l 2>/dev/null | grep -i 'recommended' | wc -l")
-		if macNum, err := parseCommandInt(macUpdates); err == nil {
-			updates = macNum
-		}
-	}
+// commandTimeout bounds every external command run by getCommandOutput. It is
+// set from COMMAND_TIMEOUT at startup.
+var commandTimeout = 10 * time.Second
 
-	return updates
+// getCommandOutput runs a command with explicit arguments, never through a
+// shell, and returns its trimmed standard output. The output is returned even
+// when the command exits non-zero, alongside the *exec.ExitError. Commands
+// still running after commandTimeout are killed.
+func getCommandOutput(ctx context.Context, name string, args ...string) (string, error) {
+	return getCommandOutputWithin(ctx, commandTimeout, name, args...)
 }
 
-// getCommandOutput runs a shell command and returns its output
-func getCommandOutput(cmdStr string) string {
-	cmd := exec.Command("sh", "-c", cmdStr)
+// getCommandOutputWithin is getCommandOutput with its own deadline, for the
+// few commands known to take longer than COMMAND_TIMEOUT
+func getCommandOutputWithin(ctx context.Context, timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessGroupOnCancel(cmd)
+	// Don't wait on pipes held open by orphaned grandchildren
+	cmd.WaitDelay = time.Second
+
 	output, err := cmd.Output()
-	if err != nil {
-		return ""
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Warn("command timed out", "command", name, "timeout", timeout.String())
 	}
-	return strings.TrimSpace(string(output))
-}
 
-// parseCommandInt parses integer from command output
-func parseCommandInt(output string) (int, error) {
-	output = strings.TrimSpace(output)
-	var value int
-	_, err := fmt.Sscanf(output, "%d", &value)
-	return value, err
+	return strings.TrimSpace(string(output)), err
 }
 
+// printVitals collects a fresh snapshot, prints a summary to the terminal and
+// responds with it as JSON, or as ?encoding= protobuf or msgpack
 func (app *application) printVitals(w http.ResponseWriter, r *http.Request) {
-	vitals := collectSystemVitals()
+	encoding, err := parseEncoding(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.collector.timeout)
+	defer cancel()
+
+	vitals := collectSystemVitals(ctx, app.source, app.config.collector.options, newCollectState())
+	vitals.AppUptime = uint64(time.Since(app.startTime).Seconds())
 
 	fmt.Println("╒═══════════════════════════════╕")
 	fmt.Println("│        SYSTEM VITALS         │")
@@ -490,4 +1229,17 @@ func (app *application) printVitals(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("│   Goroutines: %-15d │\n", vitals.GoRoutines)
 	fmt.Printf("│   Memory: %-19v │\n", vitals.GoMemAlloc)
 	fmt.Println("╘═══════════════════════════════╛")
+
+	vitals = convertTemperatures(vitals, app.config.tempUnit)
+	switch encoding {
+	case encodingProtobuf:
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(marshalVitalsProto(vitals, nil))
+	case encodingMsgpack:
+		if err := writeMsgpack(w, http.StatusOK, vitals); err != nil {
+			slog.Error("writing metric response", "error", err)
+		}
+	default:
+		app.writeMetric(w, vitals)
+	}
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectTopProcesses(t *testing.T) {
+	candidates := []TopProcess{
+		{PID: 1, CPU: 0, Memory: 0.5},
+		{PID: 2, CPU: 12, Memory: 1},
+		{PID: 3, CPU: 40, Memory: 0.1},
+		{PID: 4, CPU: 0, Memory: 25},
+		{PID: 5, CPU: 12, Memory: 3},
+		{PID: 6, CPU: 0, Memory: 0},
+	}
+
+	tests := []struct {
+		name string
+		opts processOptions
+		want []int32 // PIDs in order
+	}{
+		{
+			name: "by CPU, ties by PID",
+			opts: processOptions{sortBy: processSortCPU},
+			want: []int32{3, 2, 5, 1, 4},
+		},
+		{
+			name: "by memory",
+			opts: processOptions{sortBy: processSortMemory},
+			want: []int32{4, 5, 2, 1, 3},
+		},
+		{
+			name: "unknown sort falls back to CPU",
+			opts: processOptions{sortBy: "pid"},
+			want: []int32{3, 2, 5, 1, 4},
+		},
+		{
+			name: "limited to the top N",
+			opts: processOptions{sortBy: processSortCPU, limit: 2},
+			want: []int32{3, 2},
+		},
+		{
+			name: "either threshold qualifies",
+			opts: processOptions{sortBy: processSortCPU, cpuMin: 20, memMin: 2},
+			want: []int32{3, 5, 4},
+		},
+		{
+			name: "nothing over the thresholds falls back to every process",
+			opts: processOptions{sortBy: processSortMemory, cpuMin: 90, memMin: 90, limit: 3},
+			want: []int32{4, 5, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := slices.Clone(candidates)
+			got := make([]int32, 0)
+			for _, p := range selectTopProcesses(input, tt.opts) {
+				got = append(got, p.PID)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got PIDs %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(input, candidates) {
+				t.Error("candidates were modified")
+			}
+		})
+	}
+}
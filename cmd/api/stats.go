@@ -0,0 +1,137 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// collectorStatsWindow is how many recent passes the average and maximum
+// durations cover
+const collectorStatsWindow = 60
+
+// collectorStats measures the shared collector's own passes, to tell whether
+// the configured intervals can be kept up with
+type collectorStats struct {
+	mu          sync.Mutex
+	collections uint64
+	overruns    uint64 // passes that took longer than the interval
+	last        time.Duration
+	lastAt      time.Time
+	recent      []time.Duration // ring of the last collectorStatsWindow durations
+	next        int
+	errors      map[string]subsystemError
+}
+
+// subsystemError is the most recent failure of one metric, kept after it
+// recovers
+type subsystemError struct {
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+func newCollectorStats() *collectorStats {
+	return &collectorStats{
+		recent: make([]time.Duration, 0, collectorStatsWindow),
+		errors: make(map[string]subsystemError),
+	}
+}
+
+// observe records a collection pass that took d and ended at the given time
+func (s *collectorStats) observe(d, interval time.Duration, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.collections++
+	if d > interval {
+		s.overruns++
+	}
+	s.last, s.lastAt = d, at
+
+	if len(s.recent) < collectorStatsWindow {
+		s.recent = append(s.recent, d)
+	} else {
+		s.recent[s.next] = d
+		s.next = (s.next + 1) % collectorStatsWindow
+	}
+}
+
+// recordErrors keeps the errors reported by a pass or a metric group
+func (s *collectorStats) recordErrors(errs map[string]string, at time.Time) {
+	if len(errs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for metric, msg := range errs {
+		s.errors[metric] = subsystemError{Error: msg, At: at}
+	}
+}
+
+type statsResponse struct {
+	ConnectedClients int64 `json:"connectedClients"` // open /sse streams
+	WebSocketClients int64 `json:"webSocketClients"` // open /ws connections
+
+	Collections    uint64           `json:"collections"`    // passes since startup
+	Overruns       uint64           `json:"overruns"`       // passes that took longer than the interval
+	LastDurationMs float64          `json:"lastDurationMs"` // 0 before the first pass
+	AvgDurationMs  float64          `json:"avgDurationMs"`  // over the last 60 passes
+	MaxDurationMs  float64          `json:"maxDurationMs"`  // over the last 60 passes
+	LastCollected  *time.Time       `json:"lastCollected"`  // null before the first pass
+	IntervalMs     int64            `json:"intervalMs"`
+	TimeoutMs      int64            `json:"timeoutMs"`
+	GroupIntervals map[string]int64 `json:"groupIntervalsMs"` // metric groups on their own, longer interval
+
+	// LastErrors holds each metric's most recent collection error, including
+	// metrics that have since recovered
+	LastErrors map[string]subsystemError `json:"lastErrors"`
+}
+
+// statsHandler reports statistics about the service itself
+func (app *application) statsHandler(w http.ResponseWriter, r *http.Request) {
+	c := app.collector
+	resp := &statsResponse{
+		ConnectedClients: app.sseConnected.Load(),
+		WebSocketClients: app.wsConnected.Load(),
+		IntervalMs:       c.interval.Milliseconds(),
+		TimeoutMs:        c.timeout.Milliseconds(),
+		GroupIntervals:   make(map[string]int64),
+		LastErrors:       make(map[string]subsystemError),
+	}
+
+	for _, group := range metricGroups {
+		if c.separate(group) {
+			resp.GroupIntervals[group.name] = c.intervals[group.name].Milliseconds()
+		}
+	}
+
+	s := c.stats
+	s.mu.Lock()
+	resp.Collections = s.collections
+	resp.Overruns = s.overruns
+	resp.LastDurationMs = milliseconds(s.last)
+	if !s.lastAt.IsZero() {
+		lastAt := s.lastAt
+		resp.LastCollected = &lastAt
+	}
+	var total time.Duration
+	for _, d := range s.recent {
+		total += d
+		resp.MaxDurationMs = max(resp.MaxDurationMs, milliseconds(d))
+	}
+	if len(s.recent) > 0 {
+		resp.AvgDurationMs = milliseconds(total / time.Duration(len(s.recent)))
+	}
+	maps.Copy(resp.LastErrors, s.errors)
+	s.mu.Unlock()
+
+	app.writeMetric(w, resp)
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// swapTracker keeps the previous cumulative swap in/out counters so each pass
+// can report the paging rate since the last one
+type swapTracker struct {
+	mu        sync.Mutex
+	sin, sout uint64
+	at        time.Time
+}
+
+// collectSwapRates sets the swap in/out rates, in bytes per second, from the
+// counters gathered by collectMemory. The first pass only records a baseline.
+func collectSwapRates(vitals *SystemVitals, tracker *swapTracker) {
+	if vitals.Swap == nil {
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if !tracker.at.IsZero() {
+		elapsed := vitals.LastUpdated.Sub(tracker.at).Seconds()
+		vitals.SwapInRate = counterRate(tracker.sin, vitals.Swap.Sin, elapsed)
+		vitals.SwapOutRate = counterRate(tracker.sout, vitals.Swap.Sout, elapsed)
+	}
+
+	tracker.sin = vitals.Swap.Sin
+	tracker.sout = vitals.Swap.Sout
+	tracker.at = vitals.LastUpdated
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/host"
+)
+
+const (
+	tempUnitCelsius    = "C"
+	tempUnitFahrenheit = "F"
+)
+
+// sensorProbeTimeout bounds the startup probe for temperature sensors
+const sensorProbeTimeout = 5 * time.Second
+
+// sensorsAvailable is set by probeTemperatureSensors before collection starts
+var sensorsAvailable bool
+
+// probeTemperatureSensors looks for temperature sensors once at startup,
+// logging when there are none, as on most VMs
+func probeTemperatureSensors() {
+	ctx, cancel := context.WithTimeout(context.Background(), sensorProbeTimeout)
+	defer cancel()
+
+	temps, err := host.SensorsTemperaturesWithContext(ctx)
+	if len(temps) > 0 {
+		sensorsAvailable = true
+		return
+	}
+
+	if ctx.Err() != nil {
+		slog.Warn("probing temperature sensors timed out, temperature collection disabled", "timeout", sensorProbeTimeout.String())
+		return
+	}
+	slog.Info("no temperature sensors found, temperature collection disabled", "error", err)
+}
+
+// PackageTemp groups the coretemp sensors of one CPU package (socket)
+type PackageTemp struct {
+	Package     int        `json:"package"`
+	PackageTemp float64    `json:"packageTemp"` // 0 when the package has no sensor of its own
+	Cores       []CoreTemp `json:"cores"`
+}
+
+// CoreTemp is the temperature of one core within its package
+type CoreTemp struct {
+	Core        int     `json:"core"` // numbered within the package
+	Temperature float64 `json:"temperature"`
+}
+
+// coretemp sensor keys, as built by gopsutil from the hwmon labels: "Package
+// id 0" (or "Physical id 0" on older kernels) and "Core 0"
+var (
+	packageSensorKey = regexp.MustCompile(`^coretemp_(?:packageid|physicalid)(\d+)_input$`)
+	coreSensorKey    = regexp.MustCompile(`^coretemp_core(\d+)_input$`)
+)
+
+// groupPackageTemps groups coretemp sensors by CPU package. Core keys don't
+// name their package, and core numbers restart in each package, but every
+// package's sensors are listed together: a repeated core or package number
+// starts the next package.
+func groupPackageTemps(temps []host.TemperatureStat) []PackageTemp {
+	packages := make([]PackageTemp, 0)
+
+	var current *PackageTemp
+	hasPackage := false
+	seenCores := make(map[int]bool)
+	next := func() {
+		packages = append(packages, PackageTemp{Package: -1, Cores: make([]CoreTemp, 0)})
+		current = &packages[len(packages)-1]
+		hasPackage = false
+		clear(seenCores)
+	}
+
+	for _, temp := range temps {
+		if m := packageSensorKey.FindStringSubmatch(temp.SensorKey); m != nil {
+			if current == nil || hasPackage {
+				next()
+			}
+			current.Package, _ = strconv.Atoi(m[1])
+			current.PackageTemp = temp.Temperature
+			hasPackage = true
+			continue
+		}
+
+		if m := coreSensorKey.FindStringSubmatch(temp.SensorKey); m != nil {
+			core, _ := strconv.Atoi(m[1])
+			if current == nil || seenCores[core] {
+				next()
+			}
+			current.Cores = append(current.Cores, CoreTemp{Core: core, Temperature: temp.Temperature})
+			seenCores[core] = true
+		}
+	}
+
+	// Packages without a sensor of their own are numbered by position
+	for i := range packages {
+		if packages[i].Package < 0 {
+			packages[i].Package = i
+		}
+		sort.Slice(packages[i].Cores, func(a, b int) bool { return packages[i].Cores[a].Core < packages[i].Cores[b].Core })
+	}
+	sort.SliceStable(packages, func(a, b int) bool { return packages[a].Package < packages[b].Package })
+
+	return packages
+}
+
+type temperatureResponse struct {
+	Temperature     []host.TemperatureStat `json:"temperature"`
+	CPUPackageTemps []PackageTemp          `json:"cpuPackageTemps"`
+	TempUnit        string                 `json:"tempUnit"`
+	LastUpdated     time.Time              `json:"lastUpdated"`
+}
+
+// parseTempUnit normalizes a temperature unit, accepting C/F in either case
+func parseTempUnit(raw string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(raw)) {
+	case tempUnitCelsius:
+		return tempUnitCelsius, nil
+	case tempUnitFahrenheit:
+		return tempUnitFahrenheit, nil
+	default:
+		return "", fmt.Errorf("invalid temperature unit %q, expected C or F", raw)
+	}
+}
+
+// convertTemperatures returns vitals with every temperature expressed in unit.
+// Vitals are collected in Celsius and shared between clients, so conversion
+// works on a copy and leaves the original untouched.
+func convertTemperatures(vitals *SystemVitals, unit string) *SystemVitals {
+	if unit == vitals.TempUnit {
+		return vitals
+	}
+
+	converted := *vitals
+	converted.TempUnit = unit
+
+	converted.Temperature = make([]host.TemperatureStat, len(vitals.Temperature))
+	for i, temp := range vitals.Temperature {
+		temp.Temperature = celsiusTo(temp.Temperature, unit)
+		converted.Temperature[i] = temp
+	}
+
+	converted.CPUPackageTemps = make([]PackageTemp, len(vitals.CPUPackageTemps))
+	for i, pkg := range vitals.CPUPackageTemps {
+		pkg.PackageTemp = celsiusTo(pkg.PackageTemp, unit)
+		pkg.Cores = make([]CoreTemp, len(vitals.CPUPackageTemps[i].Cores))
+		for j, core := range vitals.CPUPackageTemps[i].Cores {
+			core.Temperature = celsiusTo(core.Temperature, unit)
+			pkg.Cores[j] = core
+		}
+		converted.CPUPackageTemps[i] = pkg
+	}
+
+	converted.GPU = make([]GPUInfo, len(vitals.GPU))
+	for i, gpu := range vitals.GPU {
+		gpu.Temperature = celsiusTo(gpu.Temperature, unit)
+		converted.GPU[i] = gpu
+	}
+
+	converted.SmartHealth = make([]SmartInfo, len(vitals.SmartHealth))
+	for i, drive := range vitals.SmartHealth {
+		drive.Temperature = celsiusTo(drive.Temperature, unit)
+		converted.SmartHealth[i] = drive
+	}
+
+	// Temperature alerts carry the reading and limit in Celsius too
+	converted.Alerts = make([]Alert, len(vitals.Alerts))
+	for i, alert := range vitals.Alerts {
+		if strings.HasPrefix(alert.Metric, "temperature:") {
+			alert.Value = celsiusTo(alert.Value, unit)
+			alert.Threshold = celsiusTo(alert.Threshold, unit)
+		}
+		converted.Alerts[i] = alert
+	}
+
+	return &converted
+}
+
+func celsiusTo(celsius float64, unit string) float64 {
+	if unit == tempUnitFahrenheit {
+		return celsius*9/5 + 32
+	}
+
+	return celsius
+}
+
+func (app *application) temperatureHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := app.parsePayloadOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vitals := &SystemVitals{LastUpdated: time.Now(), TempUnit: tempUnitCelsius}
+	collectTemperatures(r.Context(), vitals, app.config.collector.options.temperature)
+	vitals = convertTemperatures(vitals, opts.tempUnit)
+
+	app.writeMetric(w, &temperatureResponse{
+		Temperature:     vitals.Temperature,
+		CPUPackageTemps: vitals.CPUPackageTemps,
+		TempUnit:        vitals.TempUnit,
+		LastUpdated:     vitals.LastUpdated,
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/host"
+)
+
+func TestConvertTemperatures(t *testing.T) {
+	vitals := &SystemVitals{
+		TempUnit:    tempUnitCelsius,
+		Temperature: []host.TemperatureStat{{SensorKey: "cpu_thermal", Temperature: 100}},
+		Alerts: []Alert{
+			{Metric: "temperature:cpu_thermal", Value: 100, Threshold: 80, Severity: severityCritical},
+			{Metric: "cpu", Value: 95, Threshold: 90, Severity: severityWarning},
+		},
+	}
+
+	converted := convertTemperatures(vitals, tempUnitFahrenheit)
+
+	if got := converted.Temperature[0].Temperature; got != 212 {
+		t.Errorf("temperature = %v, want 212", got)
+	}
+	want := []Alert{
+		{Metric: "temperature:cpu_thermal", Value: 212, Threshold: 176, Severity: severityCritical},
+		{Metric: "cpu", Value: 95, Threshold: 90, Severity: severityWarning},
+	}
+	if len(converted.Alerts) != len(want) {
+		t.Fatalf("got %d alerts, want %d", len(converted.Alerts), len(want))
+	}
+	for i, alert := range converted.Alerts {
+		if alert != want[i] {
+			t.Errorf("alert %d = %+v, want %+v", i, alert, want[i])
+		}
+	}
+
+	// The shared snapshot stays in Celsius
+	if vitals.Alerts[0].Value != 100 || vitals.Alerts[0].Threshold != 80 {
+		t.Errorf("original alert changed to %+v", vitals.Alerts[0])
+	}
+	if vitals.Temperature[0].Temperature != 100 {
+		t.Errorf("original temperature changed to %v", vitals.Temperature[0].Temperature)
+	}
+}
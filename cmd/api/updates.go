@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// updateCheckTimeout bounds a single package manager query
+const updateCheckTimeout = 2 * time.Minute
+
+// PackageUpdate is a package with a newer version available
+type PackageUpdate struct {
+	Name             string `json:"name"`
+	CurrentVersion   string `json:"currentVersion"` // empty when the package manager doesn't report it
+	AvailableVersion string `json:"availableVersion"`
+}
+
+// updateCache holds the result of the last background update check
+var updateCache struct {
+	sync.RWMutex
+	packages []PackageUpdate
+	checked  time.Time
+}
+
+// cachedUpdateCount returns the number of available updates found by the last
+// check, or 0 before the first check completes
+func cachedUpdateCount() int {
+	updateCache.RLock()
+	defer updateCache.RUnlock()
+
+	return len(updateCache.packages)
+}
+
+// cachedUpdates returns the packages found by the last check and when it ran.
+// The time is zero before the first check completes.
+func cachedUpdates() ([]PackageUpdate, time.Time) {
+	updateCache.RLock()
+	defer updateCache.RUnlock()
+
+	return updateCache.packages, updateCache.checked
+}
+
+// runUpdateChecker lists available updates immediately and then once per
+// interval until ctx is cancelled. Package manager queries are slow and load
+// the package metadata, so they are kept off the collection path.
+func runUpdateChecker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+		start := time.Now()
+		packages, err := checkForUpdates(checkCtx)
+		cancel()
+
+		// A failed check keeps the previous result
+		if err != nil {
+			slog.Warn("checking for system updates failed", "error", err)
+		} else {
+			updateCache.Lock()
+			updateCache.packages = packages
+			updateCache.checked = time.Now()
+			updateCache.Unlock()
+
+			slog.Debug("checked for system updates", "updates", len(packages), "durationMs", time.Since(start).Milliseconds())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// packageManager describes how to list pending updates with one package
+// manager. The manager is used when its binary is on the PATH.
+type packageManager struct {
+	name  string
+	args  []string // command listing pending updates; args[0] must be on the PATH
+	parse func(output string) []PackageUpdate
+
+	// okExitCodes are non-zero exit statuses that don't indicate a failure
+	okExitCodes []int
+
+	// timeout, when longer, replaces COMMAND_TIMEOUT for slow queries
+	timeout time.Duration
+}
+
+// packageManagers are tried in order; the first one installed is used
+var packageManagers = map[string][]packageManager{
+	"linux": {
+		{name: "apt", args: []string{"apt", "list", "--upgradable"}, parse: parseAptUpdates},
+		// check-update exits 100 when updates are available
+		{name: "yum", args: []string{"yum", "check-update", "--quiet"}, parse: parseYumUpdates, okExitCodes: []int{100}},
+		// checkupdates (pacman-contrib) syncs a temporary database, so unlike
+		// pacman -Qu it doesn't depend on a recent `pacman -Sy`. Both exit
+		// non-zero when there is nothing to upgrade.
+		{name: "pacman", args: []string{"checkupdates"}, parse: parsePacmanUpdates, okExitCodes: []int{2}},
+		{name: "pacman", args: []string{"pacman", "-Qu"}, parse: parsePacmanUpdates, okExitCodes: []int{1}},
+		{name: "apk", args: []string{"apk", "version", "-l", "<"}, parse: parseApkUpdates},
+	},
+	"darwin": {
+		{name: "softwareupdate", args: []string{"softwareupdate", "-l"}, parse: parseSoftwareUpdates},
+	},
+	"windows": {
+		// Searching Windows Update routinely outlasts COMMAND_TIMEOUT
+		{name: "windows-update", args: []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", windowsUpdateQuery}, parse: parseWindowsUpdates, timeout: updateCheckTimeout},
+	},
+}
+
+// windowsUpdateQuery searches for pending updates through the Windows Update
+// Agent COM API and prints "<title>\t<KB ids>" for each. The search contacts
+// the update server, so it is given the whole update check timeout rather than
+// COMMAND_TIMEOUT.
+const windowsUpdateQuery = `$ErrorActionPreference = 'Stop'
+[Console]::OutputEncoding = [Text.Encoding]::UTF8
+$searcher = (New-Object -ComObject Microsoft.Update.Session).CreateUpdateSearcher()
+foreach ($update in $searcher.Search('IsInstalled=0 and IsHidden=0').Updates) {
+	$update.Title + [char]9 + (($update.KBArticleIDs | ForEach-Object { "KB$_" }) -join ',')
+}`
+
+var (
+	packageManagerOnce     sync.Once
+	detectedPackageManager *packageManager
+)
+
+// detectPackageManager returns the package manager for this system, or nil if
+// none is supported
+func detectPackageManager() *packageManager {
+	packageManagerOnce.Do(func() {
+		for _, pm := range packageManagers[runtime.GOOS] {
+			if _, err := exec.LookPath(pm.args[0]); err == nil {
+				detectedPackageManager = &pm
+				slog.Info("checking for updates", "packageManager", pm.name, "command", pm.args[0])
+				return
+			}
+		}
+		slog.Info("no supported package manager found, update checks disabled")
+	})
+
+	return detectedPackageManager
+}
+
+// checkForUpdates lists available system updates
+func checkForUpdates(ctx context.Context) ([]PackageUpdate, error) {
+	pm := detectPackageManager()
+	if pm == nil {
+		return make([]PackageUpdate, 0), nil
+	}
+
+	output, err := getCommandOutputWithin(ctx, max(commandTimeout, pm.timeout), pm.args[0], pm.args[1:]...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && slices.Contains(pm.okExitCodes, exitErr.ExitCode()) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pm.args[0], err)
+	}
+
+	return pm.parse(output), nil
+}
+
+// eachLine calls fn with every non-empty, trimmed line of output
+func eachLine(output string, fn func(line string)) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+}
+
+// parseAptUpdates parses lines like
+// "bash/stable 5.2.15-2+b3 amd64 [upgradable from: 5.2.15-2+b2]"
+func parseAptUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	eachLine(output, func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.Contains(fields[0], "/") {
+			return
+		}
+
+		update := PackageUpdate{
+			Name:             strings.SplitN(fields[0], "/", 2)[0],
+			AvailableVersion: fields[1],
+		}
+		if _, from, ok := strings.Cut(line, "upgradable from: "); ok {
+			update.CurrentVersion = strings.TrimSuffix(from, "]")
+		}
+		updates = append(updates, update)
+	})
+
+	return updates
+}
+
+// parseYumUpdates parses lines like "bash.x86_64  5.1.8-9.el9  baseos". The
+// installed version isn't reported.
+func parseYumUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	done := false
+	eachLine(output, func(line string) {
+		// Obsoleted packages are listed after the updates
+		if done || strings.HasPrefix(line, "Obsoleting Packages") {
+			done = true
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return
+		}
+
+		name := fields[0]
+		if i := strings.LastIndex(name, "."); i > 0 {
+			name = name[:i]
+		}
+		updates = append(updates, PackageUpdate{Name: name, AvailableVersion: fields[1]})
+	})
+
+	return updates
+}
+
+// parsePacmanUpdates parses lines like "linux 6.6.1.arch1-1 -> 6.6.2.arch1-1",
+// skipping packages marked "[ignored]" since they won't be upgraded
+func parsePacmanUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	eachLine(output, func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "->" || strings.HasSuffix(line, "[ignored]") {
+			return
+		}
+
+		updates = append(updates, PackageUpdate{
+			Name:             fields[0],
+			CurrentVersion:   fields[1],
+			AvailableVersion: fields[3],
+		})
+	})
+
+	return updates
+}
+
+// apkPackage splits an apk package-version string like "busybox-1.36.1-r2"
+var apkPackage = regexp.MustCompile(`^(.+)-(\d[^-]*-r\d+)$`)
+
+// parseApkUpdates parses lines like "busybox-1.36.1-r2  < 1.36.1-r5" after
+// the "Installed: Available:" header
+func parseApkUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	eachLine(output, func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[1] != "<" {
+			return
+		}
+
+		m := apkPackage.FindStringSubmatch(fields[0])
+		if m == nil {
+			return
+		}
+		updates = append(updates, PackageUpdate{
+			Name:             m[1],
+			CurrentVersion:   m[2],
+			AvailableVersion: fields[2],
+		})
+	})
+
+	return updates
+}
+
+// softwareUpdateVersion extracts the version from a softwareupdate Title line
+var softwareUpdateVersion = regexp.MustCompile(`Version: ([^,]+)`)
+
+// parseSoftwareUpdates parses "* Label: <name>" lines, each followed by a
+// "Title: ..., Version: <version>, ..." line
+func parseSoftwareUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	eachLine(output, func(line string) {
+		if label, ok := strings.CutPrefix(line, "* Label: "); ok {
+			updates = append(updates, PackageUpdate{Name: label})
+			return
+		}
+
+		if m := softwareUpdateVersion.FindStringSubmatch(line); m != nil && len(updates) > 0 {
+			updates[len(updates)-1].AvailableVersion = m[1]
+		}
+	})
+
+	return updates
+}
+
+// parseWindowsUpdates parses the "<title>\t<KB ids>" lines printed by
+// windowsUpdateQuery. Updates are named by title and versioned by their KB
+// articles, such as "KB5034441", when they have any; the installed version
+// isn't reported.
+func parseWindowsUpdates(output string) []PackageUpdate {
+	updates := make([]PackageUpdate, 0)
+	eachLine(output, func(line string) {
+		title, kb, _ := strings.Cut(line, "\t")
+		updates = append(updates, PackageUpdate{
+			Name:             strings.TrimSpace(title),
+			AvailableVersion: strings.TrimSpace(kb),
+		})
+	})
+
+	return updates
+}
+
+type updatesResponse struct {
+	PackageManager string          `json:"packageManager"` // empty when none is supported
+	Count          int             `json:"count"`
+	Packages       []PackageUpdate `json:"packages"`
+	LastChecked    *time.Time      `json:"lastChecked"` // null before the first check completes
+}
+
+// updatesHandler lists the packages found by the last background update check
+func (app *application) updatesHandler(w http.ResponseWriter, r *http.Request) {
+	packages, checked := cachedUpdates()
+	if packages == nil {
+		packages = make([]PackageUpdate, 0)
+	}
+
+	resp := &updatesResponse{
+		Count:    len(packages),
+		Packages: packages,
+	}
+	if pm := detectPackageManager(); pm != nil {
+		resp.PackageManager = pm.name
+	}
+	if !checked.IsZero() {
+		resp.LastChecked = &checked
+	}
+
+	app.writeMetric(w, resp)
+}
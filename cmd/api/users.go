@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/shirou/gopsutil/host"
+)
+
+// UserSession is a logged-in user, such as a console or SSH session
+type UserSession struct {
+	User      string `json:"user"`
+	Terminal  string `json:"terminal"`
+	Host      string `json:"host"`      // remote host for SSH sessions, empty for local ones
+	LoginTime int64  `json:"loginTime"` // unix seconds
+}
+
+// collectUsers lists current login sessions. Headless systems and containers
+// often have no utmp database at all, which is reported as no sessions.
+func collectUsers(ctx context.Context, vitals *SystemVitals) {
+	vitals.Users = make([]UserSession, 0)
+
+	users, err := host.UsersWithContext(ctx)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			vitals.collectFailed("Users", err)
+		}
+		return
+	}
+
+	for _, u := range users {
+		vitals.Users = append(vitals.Users, UserSession{
+			User:      u.User,
+			Terminal:  u.Terminal,
+			Host:      u.Host,
+			LoginTime: int64(u.Started),
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait bounds a single write to the client
+	wsWriteWait = 10 * time.Second
+
+	// wsPongWait is how long a client may stay silent, pongs included, before
+	// the connection is considered dead. Pings go out well within it.
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+
+	// wsMaxMessage caps the size of client control messages
+	wsMaxMessage = 4096
+)
+
+// wsControl is a control message from a WebSocket client, e.g.
+// {"interval": "10s", "fields": ["cpu", "memory"]}. Omitted keys leave the
+// setting unchanged.
+type wsControl struct {
+	Interval *string   `json:"interval"` // at least COLLECT_INTERVAL
+	Fields   *[]string `json:"fields"`   // field groups, empty for every field
+}
+
+type wsError struct {
+	Error string `json:"error"`
+}
+
+// wsHandler streams vitals over a WebSocket from the shared collector, like
+// /sse, and accepts control messages to change the interval or the fields
+// while connected
+func (app *application) wsHandler(w http.ResponseWriter, r *http.Request) {
+	// Initial payload shaping, as for /sse
+	opts, err := app.parsePayloadOptions(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: app.checkWSOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	clientID := uuid.NewString()
+	connected := app.wsConnected.Add(1)
+	defer app.wsConnected.Add(-1)
+
+	slog.Info("websocket client connected",
+		"clientId", clientID,
+		"requestId", middleware.GetReqID(r.Context()),
+		"remote", r.RemoteAddr,
+		"connectedClients", connected,
+	)
+	defer slog.Info("websocket client disconnected", "clientId", clientID)
+
+	// The reader goroutine owns all reads and exits once the connection fails
+	// or is closed on return
+	controls := make(chan []byte)
+	closed := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go readWSMessages(conn, controls, closed, quit)
+
+	updates := app.collector.subscribe()
+	defer app.collector.unsubscribe(updates)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	// With the default interval every snapshot is pushed as it's collected.
+	// A longer interval sends the latest snapshot on a ticker instead.
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	var lastID uint64
+	send := func(vitals *SystemVitals) error {
+		lastID = vitals.ID
+		return writeWS(conn, opts.apply(vitals))
+	}
+
+	if vitals := app.collector.snapshot(); vitals != nil {
+		if err := send(vitals); err != nil {
+			return
+		}
+	}
+
+	for {
+		var err error
+
+		select {
+		case <-closed:
+			return
+		case <-app.shutdown:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server closing")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+			return
+		case vitals := <-updates:
+			if tick == nil {
+				err = send(vitals)
+			}
+		case <-tick:
+			if vitals := app.collector.snapshot(); vitals != nil && vitals.ID != lastID {
+				err = send(vitals)
+			}
+		case msg := <-controls:
+			var control wsControl
+			if jsonErr := json.Unmarshal(msg, &control); jsonErr != nil {
+				err = writeWS(conn, wsError{Error: "invalid control message: " + jsonErr.Error()})
+				break
+			}
+
+			if control.Fields != nil {
+				opts.fields = parseFields(strings.Join(*control.Fields, ","))
+			}
+
+			if control.Interval != nil {
+				interval, parseErr := app.parseWSInterval(*control.Interval)
+				if parseErr != nil {
+					err = writeWS(conn, wsError{Error: parseErr.Error()})
+					break
+				}
+
+				if ticker != nil {
+					ticker.Stop()
+					ticker, tick = nil, nil
+				}
+				if interval > app.config.collector.interval {
+					ticker = time.NewTicker(interval)
+					tick = ticker.C
+				}
+			}
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+
+		if err != nil {
+			slog.Warn("writing to websocket client", "clientId", clientID, "error", err)
+			return
+		}
+	}
+}
+
+// parseWSInterval validates a requested streaming interval. Snapshots are only
+// collected once per COLLECT_INTERVAL, so shorter intervals are refused.
+func (app *application) parseWSInterval(raw string) (time.Duration, error) {
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %w", err)
+	}
+
+	if interval < app.config.collector.interval {
+		return 0, fmt.Errorf("interval must be at least %s", app.config.collector.interval)
+	}
+
+	return interval, nil
+}
+
+// checkWSOrigin accepts non-browser clients, which send no Origin, the
+// configured frontend origins and pages served from the same host
+func (app *application) checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || app.config.origins.allows(origin) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// writeWS sends v as a JSON text message
+func writeWS(conn *websocket.Conn, v any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+		return err
+	}
+
+	return conn.WriteJSON(v)
+}
+
+// readWSMessages forwards client messages to controls until the connection
+// fails, then closes closed. Pongs and every message extend the read deadline.
+func readWSMessages(conn *websocket.Conn, controls chan<- []byte, closed chan<- struct{}, quit <-chan struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) && !errors.Is(err, net.ErrClosed) {
+				slog.Debug("reading from websocket client", "error", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		select {
+		case controls <- msg:
+		case <-quit:
+			return
+		}
+	}
+}
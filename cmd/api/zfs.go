@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ZFSPoolInfo contains the health and capacity of an imported ZFS pool
+type ZFSPoolInfo struct {
+	Name        string  `json:"name"`
+	State       string  `json:"state"` // ONLINE, DEGRADED, FAULTED, OFFLINE, UNAVAIL, REMOVED or SUSPENDED
+	Size        uint64  `json:"size"`
+	Allocated   uint64  `json:"allocated"`
+	Free        uint64  `json:"free"`
+	UsedPercent float64 `json:"usedPercent"`
+	LastScrub   string  `json:"lastScrub"` // the `scan:` line of zpool status, e.g. "scrub repaired 0B in 00:10:21 with 0 errors on ..."
+}
+
+// zfsPoolOnline is the state of a healthy pool
+const zfsPoolOnline = "ONLINE"
+
+var (
+	zpoolOnce sync.Once
+	zpoolPath string
+)
+
+func lookupZpool() string {
+	zpoolOnce.Do(func() {
+		path, err := exec.LookPath("zpool")
+		if err != nil {
+			slog.Info("zpool not found, ZFS monitoring disabled")
+			return
+		}
+		zpoolPath = path
+	})
+
+	return zpoolPath
+}
+
+// collectZFSPools reports every imported pool from zpool list and zpool status
+func collectZFSPools(ctx context.Context, vitals *SystemVitals, enabled bool) {
+	vitals.ZFSPools = make([]ZFSPoolInfo, 0)
+	if !enabled {
+		return
+	}
+
+	path := lookupZpool()
+	if path == "" {
+		return
+	}
+
+	// -H drops the header and separates columns with tabs, -p prints exact bytes
+	output, err := exec.CommandContext(ctx, path, "list", "-H", "-p", "-o", "name,size,alloc,free,health").Output()
+	if err != nil {
+		vitals.collectFailed("ZFS", err)
+		return
+	}
+
+	pools, err := parseZpoolList(output)
+	if err != nil {
+		vitals.collectFailed("ZFS", err)
+		return
+	}
+	if len(pools) == 0 {
+		return
+	}
+
+	// A failed status only loses the scrub results, the capacity is still good
+	status, err := exec.CommandContext(ctx, path, "status").Output()
+	if err != nil {
+		vitals.collectFailed("ZFS", err)
+	}
+	scans := parseZpoolScans(status)
+	for i := range pools {
+		pools[i].LastScrub = scans[pools[i].Name]
+	}
+
+	vitals.ZFSPools = pools
+}
+
+// parseZpoolList parses `zpool list -H -p -o name,size,alloc,free,health`
+func parseZpoolList(output []byte) ([]ZFSPoolInfo, error) {
+	pools := make([]ZFSPoolInfo, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		if len(cols) != 5 {
+			return nil, fmt.Errorf("unexpected zpool list line %q", line)
+		}
+
+		pool := ZFSPoolInfo{Name: cols[0], State: cols[4]}
+		sizes := []*uint64{&pool.Size, &pool.Allocated, &pool.Free}
+		for i, size := range sizes {
+			// Unavailable pools report "-" for their sizes
+			if cols[i+1] == "-" {
+				continue
+			}
+			n, err := strconv.ParseUint(cols[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected zpool list line %q", line)
+			}
+			*size = n
+		}
+		if pool.Size > 0 {
+			pool.UsedPercent = float64(pool.Allocated) / float64(pool.Size) * 100
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pools, scanner.Err()
+}
+
+// parseZpoolScans maps each pool in `zpool status` output to its scan line.
+// Only the first line is kept; a scrub in progress continues on the next ones.
+func parseZpoolScans(output []byte) map[string]string {
+	scans := make(map[string]string)
+
+	pool := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "pool":
+			pool = strings.TrimSpace(value)
+		case "scan":
+			if pool != "" {
+				scans[pool] = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return scans
+}